@@ -1,16 +1,21 @@
 package sanast
 
 import (
+	"strings"
+
 	token "github.com/fgrehm/go-san/token"
 )
 
 // File represents a single SAN file
 type File struct {
 	Identifiers  *IdentifiersDefinition
+	Domains      *DomainsDefinition
 	Events       *EventsDefinition
 	Reachability *ReachabilityDefinition
 	Results      *ResultsDefinition
 	Network      *NetworkDefinition
+
+	Comments []*CommentGroup // all the comments of the file, in source order
 }
 
 // Comment node represents a single //, # style or /*- style commment
@@ -24,3 +29,14 @@ type Comment struct {
 type CommentGroup struct {
 	List []*Comment // len(List) > 0
 }
+
+// Pos returns the position of the first comment of the group
+func (g *CommentGroup) Pos() token.Pos {
+	return g.List[0].Start
+}
+
+// EndLine returns the line on which the last comment of the group ends
+func (g *CommentGroup) EndLine() int {
+	last := g.List[len(g.List)-1]
+	return last.Start.Line + strings.Count(last.Text, "\n")
+}
@@ -0,0 +1,25 @@
+package sanast
+
+import (
+	token "github.com/fgrehm/go-san/token"
+)
+
+// DomainsDefinition represents the set of domains defined on the SAN file,
+// which name ranges of indexes reused by replicated automata and states
+type DomainsDefinition struct {
+	Token       token.Token
+	Definitions []*DomainDefinition
+
+	LeadComment *CommentGroup // associated lead comment
+	LineComment *CommentGroup // associated line comment
+}
+
+// DomainDefinition represents a single domain definition present on the
+// domains block
+type DomainDefinition struct {
+	Name  token.Token // the domain name
+	Range *Range      // the range of indexes of the domain
+
+	LeadComment *CommentGroup // associated lead comment
+	LineComment *CommentGroup // associated line comment
+}
@@ -8,6 +8,9 @@ import (
 type EventsDefinition struct {
 	Token        token.Token
 	Descriptions []*EventDescription
+
+	LeadComment *CommentGroup // associated lead comment
+	LineComment *CommentGroup // associated line comment
 }
 
 // EventDescription represents a single event description present on
@@ -15,5 +18,8 @@ type EventsDefinition struct {
 type EventDescription struct {
 	Type token.Token // the type of event (local or synchronizing)
 	Name token.Token // the name of the event
-	Rate token.Token // the firing rate of the event
+	Rate *Expression // the firing rate of the event
+
+	LeadComment *CommentGroup // associated lead comment
+	LineComment *CommentGroup // associated line comment
 }
@@ -6,10 +6,11 @@ import (
 	token "github.com/fgrehm/go-san/token"
 )
 
-// Expression represents an expression used on the identifiers or results
-// definitions
+// Expression represents an expression used on the identifiers, reachability
+// or results definitions
 type Expression struct {
-	Tokens []token.Token
+	Tokens []token.Token // the tokens that make up for the expression
+	Root   Expr          // the expression tree built out of the tokens
 }
 
 // Value returns the properly typed value for this expression. The type of
@@ -38,3 +39,151 @@ func (e *Expression) Text() string {
 	}
 	return strings.Join(text, " ")
 }
+
+// Expr is implemented by every node of an expression tree
+type Expr interface {
+	// Pos returns the position of the first token of the expression
+	Pos() token.Pos
+	// String returns the canonical textual representation of the expression
+	String() string
+
+	exprNode()
+}
+
+// BasicLit represents a number or float literal
+type BasicLit struct {
+	Token token.Token
+}
+
+// Ident represents a reference to an identifier or to an automaton state.
+// Indexed states are referenced as `state[index]`.
+type Ident struct {
+	Token token.Token
+	Index Expr // the state index or nil
+}
+
+// StateExpr represents the `st Automaton` construct, which evaluates to the
+// current state of an automaton. Replicas of an automaton are referenced as
+// `st Automaton[index]`.
+type StateExpr struct {
+	St        token.Token // the st keyword
+	Automaton token.Token // the automaton name
+	Index     Expr        // the replica index or nil
+}
+
+// UnaryExpr represents an unary operator applied to an expression
+type UnaryExpr struct {
+	Op token.Token
+	X  Expr
+}
+
+// BinaryExpr represents a binary operator applied to two expressions
+type BinaryExpr struct {
+	X  Expr
+	Op token.Token
+	Y  Expr
+}
+
+// ParenExpr represents a parenthesized expression
+type ParenExpr struct {
+	Lparen token.Token
+	X      Expr
+	Rparen token.Token
+}
+
+// CondExpr represents the `cond ? x : y` conditional expression
+type CondExpr struct {
+	Cond     Expr
+	Question token.Token
+	X        Expr
+	Colon    token.Token
+	Y        Expr
+}
+
+// CallExpr represents a call to the min or max built-in functions
+type CallExpr struct {
+	Fun    token.Token // the built-in being called
+	Lparen token.Token
+	Args   []Expr
+	Rparen token.Token
+}
+
+// AggregateExpr represents the `nb [Automata] state` and `lst [Automata]
+// state` built-ins, which evaluate respectively to the number of automata and
+// to the position of the last automaton found on a given state. Automata is
+// either the name of a single automaton or of a replicated one, in which case
+// all of its replicas are taken into account.
+type AggregateExpr struct {
+	Op       token.Token // the nb or lst built-in
+	Lbrack   token.Token
+	Automata token.Token
+	Rbrack   token.Token
+	State    *Ident
+}
+
+// Pos returns the position of the literal
+func (e *BasicLit) Pos() token.Pos { return e.Token.Pos }
+
+// Pos returns the position of the identifier
+func (e *Ident) Pos() token.Pos { return e.Token.Pos }
+
+// Pos returns the position of the st keyword
+func (e *StateExpr) Pos() token.Pos { return e.St.Pos }
+
+// Pos returns the position of the operator
+func (e *UnaryExpr) Pos() token.Pos { return e.Op.Pos }
+
+// Pos returns the position of the left operand
+func (e *BinaryExpr) Pos() token.Pos { return e.X.Pos() }
+
+// Pos returns the position of the left parenthesis
+func (e *ParenExpr) Pos() token.Pos { return e.Lparen.Pos }
+
+// Pos returns the position of the condition
+func (e *CondExpr) Pos() token.Pos { return e.Cond.Pos() }
+
+// Pos returns the position of the built-in name
+func (e *CallExpr) Pos() token.Pos { return e.Fun.Pos }
+
+// Pos returns the position of the built-in name
+func (e *AggregateExpr) Pos() token.Pos { return e.Op.Pos }
+
+func (e *BasicLit) String() string { return e.Token.Text }
+func (e *Ident) String() string {
+	if e.Index != nil {
+		return e.Token.Text + "[" + e.Index.String() + "]"
+	}
+	return e.Token.Text
+}
+func (e *StateExpr) String() string {
+	if e.Index != nil {
+		return "st " + e.Automaton.Text + "[" + e.Index.String() + "]"
+	}
+	return "st " + e.Automaton.Text
+}
+func (e *UnaryExpr) String() string  { return e.Op.Text + e.X.String() }
+func (e *BinaryExpr) String() string { return e.X.String() + " " + e.Op.Text + " " + e.Y.String() }
+func (e *ParenExpr) String() string  { return "(" + e.X.String() + ")" }
+func (e *CondExpr) String() string {
+	return e.Cond.String() + " ? " + e.X.String() + " : " + e.Y.String()
+}
+func (e *CallExpr) String() string {
+	args := []string{}
+	for _, arg := range e.Args {
+		args = append(args, arg.String())
+	}
+	return e.Fun.Text + "(" + strings.Join(args, ", ") + ")"
+}
+func (e *AggregateExpr) String() string {
+	return e.Op.Text + " [" + e.Automata.Text + "] " + e.State.String()
+}
+
+func (*BasicLit) exprNode()      {}
+func (*Ident) exprNode()         {}
+func (*StateExpr) exprNode()     {}
+func (*UnaryExpr) exprNode()     {}
+func (*BinaryExpr) exprNode()    {}
+func (*ParenExpr) exprNode()     {}
+func (*CondExpr) exprNode()      {}
+func (*CallExpr) exprNode()      {}
+func (*AggregateExpr) exprNode() {}
@@ -9,6 +9,9 @@ import (
 type IdentifiersDefinition struct {
 	Token       token.Token
 	Assignments []*IdentifierAssignment
+
+	LeadComment *CommentGroup // associated lead comment
+	LineComment *CommentGroup // associated line comment
 }
 
 // IdentifierAssignment represents a single identifier definition present on
@@ -16,4 +19,7 @@ type IdentifiersDefinition struct {
 type IdentifierAssignment struct {
 	Identifier token.Token // the identifier name itself
 	Expression *Expression // the value to be assigned to the identifier
+
+	LeadComment *CommentGroup // associated lead comment
+	LineComment *CommentGroup // associated line comment
 }
@@ -11,6 +11,9 @@ type NetworkDefinition struct {
 	Name     token.Token
 	Type     token.Token
 	Automata []*AutomatonDescription
+
+	LeadComment *CommentGroup // associated lead comment
+	LineComment *CommentGroup // associated line comment
 }
 
 // AutomatonDescription represents a single automaton definition present on
@@ -18,20 +21,54 @@ type NetworkDefinition struct {
 type AutomatonDescription struct {
 	Token       token.Token
 	Name        token.Token
+	Replication *Range // nil for automata that are not replicated
+	States      []*AutomatonState
 	Transitions []*AutomatonTransition
+
+	LeadComment *CommentGroup // associated lead comment
+	LineComment *CommentGroup // associated line comment
+}
+
+// AutomatonState represents a single state declaration present on the
+// automaton block inside the network block
+type AutomatonState struct {
+	Token token.Token // the stt keyword
+	Name  token.Token
+	Range *Range // nil unless the state is indexed or ranged
+
+	LeadComment *CommentGroup // associated lead comment
+	LineComment *CommentGroup // associated line comment
 }
 
 // AutomatonTransition represents a single automaton transition present on
 // the automaton block inside the network block
 type AutomatonTransition struct {
-	From   token.Token
-	To     token.Token
-	Events []*TransitionEventDescription
+	Token     token.Token // the to keyword
+	From      token.Token
+	FromRange *Range // nil unless the state is indexed or ranged
+	To        token.Token
+	ToIndex   *Expression // nil unless the target state is indexed
+	Events    []*TransitionEventDescription
+
+	LeadComment *CommentGroup // associated lead comment
+	LineComment *CommentGroup // associated line comment
+}
+
+// Range represents the bracketed suffix used on replicated automata and on
+// indexed or ranged states. It either holds a `[low..high]` range of indexes
+// or a single `[index]`, which stands for the number of replicas of an
+// automaton or the index of a state. A single domain name can be used in both
+// cases to refer to a range of indexes.
+type Range struct {
+	Lbrack token.Token
+	Low    *Expression
+	High   *Expression // nil unless a range of indexes is given
+	Rbrack token.Token
 }
 
 // TransitionEventDescription represents a single automaton transition event
 // present on the automaton block inside the network block
 type TransitionEventDescription struct {
 	EventName   token.Token
-	Probability token.Token
+	Probability *Expression // nil unless a routing probability is given
 }
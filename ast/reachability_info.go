@@ -9,4 +9,7 @@ import (
 type ReachabilityDefinition struct {
 	Tokens     []token.Token
 	Expression *Expression
+
+	LeadComment *CommentGroup // associated lead comment
+	LineComment *CommentGroup // associated line comment
 }
@@ -9,6 +9,9 @@ import (
 type ResultsDefinition struct {
 	Token        token.Token
 	Descriptions []*ResultDescription
+
+	LeadComment *CommentGroup // associated lead comment
+	LineComment *CommentGroup // associated line comment
 }
 
 // ResultDescription represents a single result description present on
@@ -16,4 +19,7 @@ type ResultsDefinition struct {
 type ResultDescription struct {
 	Label      token.Token // the result name itself
 	Expression *Expression // the expression that represents the result
+
+	LeadComment *CommentGroup // associated lead comment
+	LineComment *CommentGroup // associated line comment
 }
@@ -0,0 +1,41 @@
+package sanast
+
+// Inspect traverses an expression tree in depth-first order: it starts by
+// calling f(e); e must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of e.
+func Inspect(e Expr, f func(Expr) bool) {
+	if !f(e) {
+		return
+	}
+
+	switch n := e.(type) {
+	case *UnaryExpr:
+		Inspect(n.X, f)
+	case *BinaryExpr:
+		Inspect(n.X, f)
+		Inspect(n.Y, f)
+	case *ParenExpr:
+		Inspect(n.X, f)
+	case *Ident:
+		if n.Index != nil {
+			Inspect(n.Index, f)
+		}
+	case *StateExpr:
+		if n.Index != nil {
+			Inspect(n.Index, f)
+		}
+	case *CondExpr:
+		Inspect(n.Cond, f)
+		Inspect(n.X, f)
+		Inspect(n.Y, f)
+	case *CallExpr:
+		for _, arg := range n.Args {
+			Inspect(arg, f)
+		}
+	case *AggregateExpr:
+		// the state is not an identifier, only its index is an expression
+		if n.State.Index != nil {
+			Inspect(n.State.Index, f)
+		}
+	}
+}
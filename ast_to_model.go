@@ -1,32 +1,40 @@
 package san
 
 import (
+	"fmt"
+	"math"
+
 	ast "github.com/fgrehm/go-san/ast"
+	saneval "github.com/fgrehm/go-san/eval"
 	model "github.com/fgrehm/go-san/model"
+	parser "github.com/fgrehm/go-san/parser"
 	token "github.com/fgrehm/go-san/token"
 )
 
-type translator func(*model.Model, *ast.File)
+type translator func(*model.Model, *ast.File) error
 
 var translators = []translator{
 	translateIdentifiers,
+	translateDomains,
 	translateEvents,
 	translateReachabilityInfo,
 	translateNetwork,
 	translateResults,
 }
 
-func translateAstToModel(file *ast.File) *model.Model {
+func translateAstToModel(file *ast.File) (*model.Model, error) {
 	model := model.New()
 	for _, t := range translators {
-		t(model, file)
+		if err := t(model, file); err != nil {
+			return nil, err
+		}
 	}
-	return model
+	return model, nil
 }
 
-func translateIdentifiers(m *model.Model, f *ast.File) {
+func translateIdentifiers(m *model.Model, f *ast.File) error {
 	if f.Identifiers == nil {
-		return
+		return nil
 	}
 
 	for _, assignment := range f.Identifiers.Assignments {
@@ -34,13 +42,37 @@ func translateIdentifiers(m *model.Model, f *ast.File) {
 			Name:  assignment.Identifier.Text,
 			Type:  assignment.Expression.Type(),
 			Value: assignment.Expression.Value(),
+			Expr:  assignment.Expression.Root,
+			Pos:   assignment.Identifier.Pos,
 		})
 	}
+	return nil
 }
 
-func translateEvents(m *model.Model, f *ast.File) {
+func translateDomains(m *model.Model, f *ast.File) error {
+	if f.Domains == nil {
+		return nil
+	}
+
+	scope := &lazyScope{m: m}
+	for _, domain := range f.Domains.Definitions {
+		low, high, err := rangeBounds(scope, domain.Range)
+		if err != nil {
+			return err
+		}
+		m.AddDomain(&model.Domain{
+			Name: domain.Name.Text,
+			Low:  low,
+			High: high,
+			Pos:  domain.Name.Pos,
+		})
+	}
+	return nil
+}
+
+func translateEvents(m *model.Model, f *ast.File) error {
 	if f.Events == nil {
-		return
+		return nil
 	}
 
 	for _, event := range f.Events.Descriptions {
@@ -50,70 +82,338 @@ func translateEvents(m *model.Model, f *ast.File) {
 		}
 
 		m.AddEvent(&model.Event{
-			Name: event.Name.Text,
-			Type: eventType,
-			Rate: event.Rate.Text,
+			Name:     event.Name.Text,
+			Type:     eventType,
+			Rate:     event.Rate.Root.String(),
+			RateExpr: event.Rate.Root,
+			Pos:      event.Name.Pos,
 		})
 	}
+	return nil
 }
 
-func translateReachabilityInfo(m *model.Model, f *ast.File) {
+func translateReachabilityInfo(m *model.Model, f *ast.File) error {
 	if f.Reachability == nil {
-		return
+		return nil
 	}
 
 	m.Reachability.Partial = f.Reachability.Tokens[0].Type == token.PARTIAL
 	m.Reachability.Expression = f.Reachability.Expression.Text()
+	m.Reachability.Expr = f.Reachability.Expression.Root
+	return nil
 }
 
-func translateNetwork(m *model.Model, f *ast.File) {
+func translateNetwork(m *model.Model, f *ast.File) error {
 	if f.Network == nil {
-		return
+		return nil
 	}
 
 	m.Network.Name = f.Network.Name.Text
 	m.Network.Type = f.Network.Type.Text
 
+	scope := &lazyScope{m: m}
 	for _, automaton := range f.Network.Automata {
-		translateAutomaton(m.Network, automaton)
+		if err := translateAutomaton(m, scope, automaton); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func translateAutomaton(m *model.Model, scope *lazyScope, a *ast.AutomatonDescription) error {
+	if a.Replication == nil {
+		aut, err := buildAutomaton(m, a.Name.Text, scope, a)
+		if err != nil {
+			return err
+		}
+		m.Network.AddAutomaton(aut)
+		return nil
 	}
+
+	low, high, err := replicaIndexes(m, scope, a.Replication)
+	if err != nil {
+		return err
+	}
+	for i := low; i <= high; i++ {
+		aut, err := buildAutomaton(m, model.ReplicaName(a.Name.Text, i), scope, a)
+		if err != nil {
+			return err
+		}
+		aut.Group = a.Name.Text
+		m.Network.AddAutomaton(aut)
+	}
+	return nil
 }
 
-func translateAutomaton(n *model.Network, a *ast.AutomatonDescription) {
+// replicaIndexes returns the indexes of the replicas of a replicated
+// automaton, which are either given by their number, a range or a domain
+func replicaIndexes(m *model.Model, scope *lazyScope, r *ast.Range) (int, int, error) {
+	if d := rangeDomain(m, r); d != nil {
+		return d.Low, d.High, nil
+	}
+	if r.High != nil {
+		return rangeBounds(scope, r)
+	}
+
+	size, err := evalIndex(scope, nil, r.Low)
+	if err != nil {
+		return 0, 0, err
+	}
+	if size < 1 {
+		return 0, 0, &parser.PosError{Pos: r.Low.Root.Pos(), Err: fmt.Errorf("Invalid number of replicas %d", size)}
+	}
+	return 0, size - 1, nil
+}
+
+// rangeDomain returns the domain referenced by a range like `[D]`, nil is
+// returned if the range does not reference a domain
+func rangeDomain(m *model.Model, r *ast.Range) *model.Domain {
+	ident, ok := r.Low.Root.(*ast.Ident)
+	if !ok || r.High != nil || ident.Index != nil {
+		return nil
+	}
+	return m.Domain(ident.Token.Text)
+}
+
+// rangeBounds evaluates the bounds of a `[low..high]` range
+func rangeBounds(scope *lazyScope, r *ast.Range) (int, int, error) {
+	low, err := evalIndex(scope, nil, r.Low)
+	if err != nil {
+		return 0, 0, err
+	}
+	high, err := evalIndex(scope, nil, r.High)
+	if err != nil {
+		return 0, 0, err
+	}
+	if low < 0 || high < low {
+		return 0, 0, &parser.PosError{Pos: r.Lbrack.Pos, Err: fmt.Errorf("Invalid range of indexes [%d..%d]", low, high)}
+	}
+	return low, high, nil
+}
+
+// rangeIndex is the name bound to the index of the current state while
+// expanding indexed and ranged states, as in `stt q[0..K] to(q[i+1]) e`
+const rangeIndex = "i"
+
+// concreteState is a state that results from expanding a state declaration
+type concreteState struct {
+	name  string
+	index *float64 // the value bound to rangeIndex, nil for plain states
+}
+
+func buildAutomaton(m *model.Model, name string, scope *lazyScope, a *ast.AutomatonDescription) (*model.Automaton, error) {
 	aut := &model.Automaton{
-		Name:        a.Name.Text,
+		Name:        name,
+		States:      []string{},
 		Transitions: model.Transitions{},
+		Pos:         a.Name.Pos,
+	}
+
+	declared := map[string]bool{}
+	bounds := map[string]indexBounds{}
+	expansions := make([][]concreteState, len(a.States))
+	for i, state := range a.States {
+		states, err := expandState(m, scope, state)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range states {
+			if !declared[s.name] {
+				declared[s.name] = true
+				aut.AddState(s.name)
+			}
+			if s.index != nil {
+				bounds[state.Name.Text] = bounds[state.Name.Text].extend(int(*s.index))
+			}
+		}
+		expansions[i] = states
 	}
+
+	transitions := map[token.Pos][]*ast.AutomatonTransition{}
 	for _, transition := range a.Transitions {
-		translateTransition(aut, transition)
+		transitions[transition.From.Pos] = append(transitions[transition.From.Pos], transition)
 	}
-	n.AddAutomaton(aut)
+
+	for i, state := range a.States {
+		for _, from := range expansions[i] {
+			for _, transition := range transitions[state.Name.Pos] {
+				to, index, err := transitionTarget(scope, from, transition)
+				if err != nil {
+					return nil, err
+				}
+				// targets relative to the index of a ranged state that fall
+				// outside the indexes declared for the target are dropped so
+				// that `to(q[i+1])` can be used for a whole range of states,
+				// any other undeclared indexed target is an error
+				if transition.ToIndex != nil && !declared[to] {
+					if from.index != nil && refersTo(transition.ToIndex.Root, rangeIndex) && bounds[transition.To.Text].excludes(index) {
+						continue
+					}
+					return nil, &parser.PosError{Pos: transition.ToIndex.Root.Pos(), Err: fmt.Errorf("Transition from %q of automaton %q leads to undeclared state %q", from.name, name, to)}
+				}
+				// targets that are not declared with stt are still states of
+				// the automaton
+				if !declared[to] {
+					declared[to] = true
+					aut.AddState(to)
+				}
+				translateTransition(aut, from.name, to, transition)
+			}
+		}
+	}
+	return aut, nil
+}
+
+// indexBounds holds the lowest and highest indexes declared for the states
+// sharing a name
+type indexBounds struct {
+	low, high int
+	set       bool
+}
+
+func (b indexBounds) extend(index int) indexBounds {
+	if !b.set {
+		return indexBounds{low: index, high: index, set: true}
+	}
+	if index < b.low {
+		b.low = index
+	}
+	if index > b.high {
+		b.high = index
+	}
+	return b
+}
+
+// excludes returns true if index lies outside the declared indexes, names
+// without indexed states have no bounds to lie outside of
+func (b indexBounds) excludes(index int) bool {
+	return b.set && (index < b.low || index > b.high)
+}
+
+// expandState returns the concrete states declared by a single stt
+func expandState(m *model.Model, scope *lazyScope, state *ast.AutomatonState) ([]concreteState, error) {
+	if state.Range == nil {
+		return []concreteState{{name: state.Name.Text}}, nil
+	}
+
+	var low, high int
+	var err error
+	if d := rangeDomain(m, state.Range); d != nil {
+		low, high = d.Low, d.High
+	} else if state.Range.High != nil {
+		low, high, err = rangeBounds(scope, state.Range)
+	} else {
+		low, err = evalIndex(scope, nil, state.Range.Low)
+		high = low
+		if err == nil && low < 0 {
+			err = &parser.PosError{Pos: state.Range.Low.Root.Pos(), Err: fmt.Errorf("Invalid state index %d", low)}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	states := []concreteState{}
+	for i := low; i <= high; i++ {
+		index := float64(i)
+		states = append(states, concreteState{name: model.ReplicaName(state.Name.Text, i), index: &index})
+	}
+	return states, nil
+}
+
+// transitionTarget returns the name of the state a transition leads to when
+// fired from the given state along with its index, if the target is indexed
+func transitionTarget(scope *lazyScope, from concreteState, t *ast.AutomatonTransition) (string, int, error) {
+	if t.ToIndex == nil {
+		return t.To.Text, 0, nil
+	}
+	index, err := evalIndex(scope, from.index, t.ToIndex)
+	if err != nil {
+		return "", 0, err
+	}
+	return model.ReplicaName(t.To.Text, index), index, nil
 }
 
-func translateTransition(a *model.Automaton, t *ast.AutomatonTransition) {
+// evalIndex evaluates the index of a state, binding rangeIndex to the given
+// index if present and referenced by the expression. Identifiers named after
+// rangeIndex would be shadowed by the binding and are reported as errors when
+// the expression refers to the index.
+func evalIndex(scope *lazyScope, index *float64, e *ast.Expression) (int, error) {
+	s, err := scope.get()
+	if err != nil {
+		return 0, err
+	}
+	if index != nil && refersTo(e.Root, rangeIndex) {
+		if _, ok := s.Values[rangeIndex]; ok || s.IsFunctional(rangeIndex) {
+			return 0, &parser.PosError{Pos: e.Root.Pos(), Err: fmt.Errorf("Identifier %q clashes with the index of ranged states", rangeIndex)}
+		}
+		s = s.Bind(saneval.Values{rangeIndex: *index})
+	}
+
+	val, err := s.Eval(e.Root, nil)
+	if err != nil {
+		return 0, err
+	}
+	if val != math.Trunc(val) {
+		return 0, &parser.PosError{Pos: e.Root.Pos(), Err: fmt.Errorf("Invalid state index %v", val)}
+	}
+	return int(val), nil
+}
+
+// refersTo returns true if the expression references the identifier name
+func refersTo(e ast.Expr, name string) bool {
+	found := false
+	ast.Inspect(e, func(n ast.Expr) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.Token.Text == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+func translateTransition(a *model.Automaton, from, to string, t *ast.AutomatonTransition) {
 	events := model.TransitionEvents{}
 	for _, e := range t.Events {
-		events = append(events, &model.TransitionEvent{
-			EventName:   e.EventName.Text,
-			Probability: e.Probability.Text,
-		})
+		event := &model.TransitionEvent{EventName: e.EventName.Text, Pos: e.EventName.Pos}
+		if e.Probability != nil {
+			event.Probability = e.Probability.Root.String()
+			event.ProbabilityExpr = e.Probability.Root
+		}
+		events = append(events, event)
 	}
 	a.AddTransition(&model.Transition{
-		From:   t.From.Text,
-		To:     t.To.Text,
+		From:   from,
+		To:     to,
 		Events: events,
 	})
 }
 
-func translateResults(m *model.Model, f *ast.File) {
+// lazyScope evaluates the model identifiers the first time they are needed
+type lazyScope struct {
+	m     *model.Model
+	scope *saneval.Scope
+	err   error
+}
+
+func (l *lazyScope) get() (*saneval.Scope, error) {
+	if l.scope == nil && l.err == nil {
+		l.scope, l.err = saneval.Evaluate(l.m)
+	}
+	return l.scope, l.err
+}
+
+func translateResults(m *model.Model, f *ast.File) error {
 	if f.Results == nil {
-		return
+		return nil
 	}
 
 	for _, desc := range f.Results.Descriptions {
 		m.AddResult(&model.Result{
 			Label:      desc.Label.Text,
 			Expression: desc.Expression.Text(),
+			Expr:       desc.Expression.Root,
 		})
 	}
+	return nil
 }
@@ -0,0 +1,381 @@
+package sanctmc
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	san "github.com/fgrehm/go-san"
+	model "github.com/fgrehm/go-san/model"
+)
+
+const clientServer = `
+identifiers
+  r_req = 2;
+  r_resp = 3;
+  r_proc = 5;
+
+events
+  syn s_req (r_req);
+  syn s_resp (r_resp);
+  loc l_proc (r_proc);
+
+reachability = 1;
+
+network ClientServer (continuous)
+  aut Client
+    stt Idle to (Waiting) s_req
+    stt Waiting to (Idle) s_resp
+  aut Server
+    stt Idle to (Busy) s_req
+    stt Busy to (Done) l_proc
+    stt Done to (Idle) s_resp
+
+results
+  idle = st Client == Idle;
+`
+
+func TestStateSpace(t *testing.T) {
+	n := compile(t, clientServer)
+	space := n.Space
+
+	if space.Len() != 6 {
+		t.Fatalf("want: 6 states got: %d", space.Len())
+	}
+	local := make([]int, 2)
+	for i := 0; i < space.Len(); i++ {
+		space.Decode(i, local)
+		if space.Encode(local) != i {
+			t.Errorf("want: %d got: %d for %v", i, space.Encode(local), local)
+		}
+	}
+	if name := space.Name(4); name != "Client=Waiting Server=Busy" {
+		t.Errorf("want: %q got: %q", "Client=Waiting Server=Busy", name)
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	var testData = []struct {
+		original string
+		replaced string
+		expected map[[2]int]float64
+	}{
+		// Client states are Idle and Waiting, Server states are Idle, Busy and
+		// Done, global states are numbered as client * 3 + server
+		{"", "", map[[2]int]float64{
+			{0, 4}: 2, {1, 2}: 5, {4, 5}: 5, {5, 0}: 3,
+		}},
+		{"loc l_proc (r_proc);", "loc l_proc (r_proc * (st Client == Waiting));", map[[2]int]float64{
+			{0, 4}: 2, {4, 5}: 5, {5, 0}: 3,
+		}},
+		{"stt Busy to (Done) l_proc", "stt Busy to (Done) l_proc(0.4) to (Idle) l_proc(0.6)", map[[2]int]float64{
+			{0, 4}: 2, {1, 2}: 2, {1, 0}: 3, {4, 5}: 2, {4, 3}: 3, {5, 0}: 3,
+		}},
+		{"stt Idle to (Waiting) s_req", "stt Idle to (Waiting) s_req(0.5) to (Idle) s_req(0.5)", map[[2]int]float64{
+			{0, 4}: 1, {0, 1}: 1, {1, 2}: 5, {4, 5}: 5, {5, 0}: 3,
+		}},
+	}
+
+	for _, d := range testData {
+		n := compile(t, strings.Replace(clientServer, d.original, d.replaced, 1))
+		q, err := n.Generator()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertGenerator(t, q, d.expected)
+	}
+}
+
+func TestGenerator_Replicas(t *testing.T) {
+	n := compile(t, `
+identifiers
+  r = 1;
+events
+  loc l_work (r);
+reachability = 1;
+network Farm (continuous)
+  aut Worker[2]
+    stt Idle to (Busy) l_work
+    stt Busy to (Idle) l_work
+`)
+	q, err := n.Generator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGenerator(t, q, map[[2]int]float64{
+		{0, 1}: 1, {0, 2}: 1,
+		{1, 0}: 1, {1, 3}: 1,
+		{2, 3}: 1, {2, 0}: 1,
+		{3, 2}: 1, {3, 1}: 1,
+	})
+}
+
+func TestGenerator_Discrete(t *testing.T) {
+	src := `
+identifiers
+  p = 0.3;
+events
+  loc l_send (p);
+  loc l_ack (0.5);
+reachability = 1;
+network Protocol (discrete)
+  aut Sender
+    stt Ready to (Waiting) l_send
+    stt Waiting to (Ready) l_ack
+                to (Waiting) l_ack
+`
+	n := compile(t, src)
+	if !n.Discrete {
+		t.Error("Expected network to be discrete")
+	}
+	q, err := n.Generator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the self loop on Waiting is kept on the diagonal of P
+	assertGenerator(t, q, map[[2]int]float64{{0, 1}: 0.3, {1, 0}: 0.5})
+
+	n = compile(t, strings.Replace(src, "loc l_ack (0.5);", "loc l_ack (0.6);", 1))
+	if _, err := n.Generator(); err == nil || !strings.Contains(err.Error(), "Probabilities leaving state Sender=Waiting add up to 1.2") {
+		t.Errorf("Expected to error with probabilities over 1, got %v", err)
+	}
+}
+
+func TestReachable(t *testing.T) {
+	var testData = []struct {
+		reachability string
+		expected     []int
+	}{
+		{"reachability = 1;", []int{0, 1, 2, 3, 4, 5}},
+		{"reachability = st Client == Idle;", []int{0, 1, 2}},
+		{"partial reachability = (st Client == Idle) && (st Server == Idle);", []int{0, 4, 5}},
+		{"partial reachability = st Server == Busy;", []int{1, 2, 4, 5, 0}},
+	}
+
+	for _, d := range testData {
+		n := compile(t, strings.Replace(clientServer, "reachability = 1;", d.reachability, 1))
+		sub, err := n.Reachable()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sub.Len() != len(d.expected) {
+			t.Errorf("want: %v got: %v for %q", d.expected, sub.States, d.reachability)
+			continue
+		}
+		for _, s := range d.expected {
+			if _, ok := sub.Index(s); !ok {
+				t.Errorf("want: %v got: %v for %q", d.expected, sub.States, d.reachability)
+				break
+			}
+		}
+	}
+
+	n := compile(t, strings.Replace(clientServer, "reachability = 1;", "reachability = 0;", 1))
+	if _, err := n.Reachable(); err == nil || !strings.Contains(err.Error(), "The reachability function does not hold on any state") {
+		t.Errorf("Expected an error for an empty reachable set, got %v", err)
+	}
+}
+
+func TestInitial(t *testing.T) {
+	var testData = []struct {
+		reachability string
+		expected     int
+	}{
+		{"reachability = 1;", 0},
+		{"reachability = st Server == Done;", 2},
+		{"partial reachability = (st Client == Waiting) && (st Server == Busy);", 4},
+	}
+
+	for _, d := range testData {
+		n := compile(t, strings.Replace(clientServer, "reachability = 1;", d.reachability, 1))
+		state, err := n.Initial()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state != d.expected {
+			t.Errorf("want: %d got: %d for %q", d.expected, state, d.reachability)
+		}
+	}
+
+	n := compile(t, strings.Replace(clientServer, "reachability = 1;", "reachability = 0;", 1))
+	if _, err := n.Initial(); err == nil || !strings.Contains(err.Error(), "The reachability function does not hold on any state") {
+		t.Errorf("Expected an error for an empty reachable set, got %v", err)
+	}
+}
+
+func TestRateBound(t *testing.T) {
+	var testData = []struct {
+		rate     string
+		expected []float64
+	}{
+		{"loc l_proc (r_proc);", []float64{2, 3, 5}},
+		{"loc l_proc (r_proc * (st Client == Waiting));", []float64{2, 3, 5}},
+		{"loc l_proc ((st Client == Waiting) ? 2 * r_proc : 1);", []float64{2, 3, 10}},
+	}
+
+	for _, d := range testData {
+		n := compile(t, strings.Replace(clientServer, "loc l_proc (r_proc);", d.rate, 1))
+		bounds := []float64{}
+		for i := range n.Events() {
+			bound, err := n.RateBound(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bounds = append(bounds, bound)
+		}
+		if !reflect.DeepEqual(d.expected, bounds) {
+			t.Errorf("want: %v got: %v for %q", d.expected, bounds, d.rate)
+		}
+	}
+}
+
+func TestSubspaceGenerator(t *testing.T) {
+	n := compile(t, strings.Replace(clientServer, "reachability = 1;", "partial reachability = (st Client == Idle) && (st Server == Idle);", 1))
+	sub, err := n.Reachable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := n.SubspaceGenerator(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Size() != 3 {
+		t.Fatalf("want: 3 states got: %d", q.Size())
+	}
+	assertGenerator(t, q, map[[2]int]float64{{0, 1}: 2, {1, 2}: 5, {2, 0}: 3})
+
+	// Waiting clients are left out, so requests lead out of the subspace
+	n = compile(t, strings.Replace(clientServer, "reachability = 1;", "reachability = st Client == Idle;", 1))
+	if sub, err = n.Reachable(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.SubspaceGenerator(sub); err == nil || !strings.Contains(err.Error(), `Event "s_req" leads from state Client=Idle Server=Idle to the unreachable state Client=Waiting Server=Busy`) {
+		t.Errorf("Expected an error for a transition leaving the subspace, got %v", err)
+	}
+}
+
+func TestCompile_Error(t *testing.T) {
+	var testData = []struct {
+		original string
+		replaced string
+		expected string
+	}{
+		{"stt Busy to (Done) l_proc", "stt Busy to (Done) l_foo", `references undefined event "l_foo"`},
+		{"stt Idle to (Waiting) s_req", "stt Idle to (Waiting) l_proc", `Synchronizing event "s_req" must be used by at least two automata, found 1`},
+		{"r_proc = 5;", "r_proc = foo;", `Undefined identifier "foo"`},
+		{"loc l_proc (r_proc);", "loc l_proc (r_foo);", `Undefined identifier "r_foo"`},
+		{"loc l_proc (r_proc);", "loc l_proc (r_foo * (st Client == Idle));", `Undefined identifier "r_foo"`},
+		{"stt Idle to (Waiting) s_req", "stt Idle to (Waiting) s_req(p_foo)", `Undefined identifier "p_foo"`},
+	}
+
+	for _, d := range testData {
+		m, err := san.Parse([]byte(strings.Replace(clientServer, d.original, d.replaced, 1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = Compile(m)
+		if err == nil || !strings.Contains(err.Error(), d.expected) {
+			t.Errorf("want: %q got: %v", d.expected, err)
+		}
+	}
+}
+
+func TestGenerator_Error(t *testing.T) {
+	n := compile(t, strings.Replace(clientServer, "loc l_proc (r_proc);", "loc l_proc (r_proc - 10 * (st Client == Idle));", 1))
+	if _, err := n.Generator(); err == nil || !strings.Contains(err.Error(), `Event "l_proc" has a negative rate -5`) {
+		t.Errorf("Expected to error with a negative rate, got %v", err)
+	}
+}
+
+func TestCompile_HandBuiltModel(t *testing.T) {
+	m := model.New()
+	m.AddIdentifier(&model.Identifier{Name: "r", Type: "constant", Value: int64(2)})
+	m.AddEvent(&model.Event{Name: "e", Type: "local", Rate: "r"})
+	m.Network.Name = "Net"
+	m.Network.Type = "continuous"
+	m.Network.AddAutomaton(&model.Automaton{
+		Name: "A",
+		Transitions: model.Transitions{
+			{From: "a", To: "b", Events: model.TransitionEvents{{EventName: "e", Probability: "0.5"}}},
+			{From: "b", To: "a", Events: model.TransitionEvents{{EventName: "e"}}},
+		},
+	})
+
+	n, err := Compile(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := n.Generator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGenerator(t, q, map[[2]int]float64{{0, 1}: 1, {1, 0}: 2})
+}
+
+func TestMatrixVecMul(t *testing.T) {
+	b := NewMatrixBuilder(3)
+	b.Add(0, -2)
+	b.Add(2, 2)
+	b.EndRow()
+	b.EndRow()
+	b.Add(1, 4)
+	b.Add(0, 1)
+	b.Add(0, 1)
+	b.EndRow()
+	m := b.Matrix()
+
+	y := make([]float64, 3)
+	m.VecMul([]float64{1, 2, 3}, y)
+	expected := []float64{4, 12, 2}
+	for i := range y {
+		if y[i] != expected[i] {
+			t.Errorf("want: %v got: %v", expected, y)
+			break
+		}
+	}
+	if diag := m.Diagonal(); diag[0] != -2 || diag[1] != 0 || diag[2] != 0 {
+		t.Errorf("want: [-2 0 0] got: %v", diag)
+	}
+	if m.At(2, 0) != 2 || m.At(1, 1) != 0 {
+		t.Errorf("Unexpected elements on %+v", m)
+	}
+	tr := m.Transpose()
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			if tr.At(j, i) != m.At(i, j) {
+				t.Errorf("want: %v got: %v at (%d, %d) of the transpose", m.At(i, j), tr.At(j, i), j, i)
+			}
+		}
+	}
+}
+
+func compile(t *testing.T, src string) *Network {
+	m, err := san.Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := Compile(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// assertGenerator checks the off diagonal elements of a generator against
+// the expected ones and that every row adds up to zero
+func assertGenerator(t *testing.T, q *Matrix, expected map[[2]int]float64) {
+	for i := 0; i < q.Size(); i++ {
+		sum := 0.0
+		for j := 0; j < q.Size(); j++ {
+			v := q.At(i, j)
+			sum += v
+			if i != j && math.Abs(v-expected[[2]int{i, j}]) > 1e-12 {
+				t.Errorf("want: %v got: %v at (%d, %d)", expected[[2]int{i, j}], v, i, j)
+			}
+		}
+		if math.Abs(sum) > 1e-12 {
+			t.Errorf("row %d adds up to %v", i, sum)
+		}
+	}
+}
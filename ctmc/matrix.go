@@ -0,0 +1,126 @@
+package sanctmc
+
+import (
+	"sort"
+)
+
+// Matrix is a square sparse matrix stored in compressed sparse row format
+type Matrix struct {
+	N      int       // the number of rows and columns
+	RowPtr []int     // the entries of row i are found at [RowPtr[i], RowPtr[i+1])
+	ColIdx []int     // the column of each entry
+	Val    []float64 // the value of each entry
+}
+
+// Size returns the number of rows and columns of the matrix
+func (m *Matrix) Size() int {
+	return m.N
+}
+
+// At returns the element at row i and column j
+func (m *Matrix) At(i, j int) float64 {
+	cols := m.ColIdx[m.RowPtr[i]:m.RowPtr[i+1]]
+	k := sort.SearchInts(cols, j)
+	if k < len(cols) && cols[k] == j {
+		return m.Val[m.RowPtr[i]+k]
+	}
+	return 0
+}
+
+// VecMul computes the row vector by matrix product y = x * m
+func (m *Matrix) VecMul(x, y []float64) {
+	for j := range y {
+		y[j] = 0
+	}
+	for i := 0; i < m.N; i++ {
+		if x[i] == 0 {
+			continue
+		}
+		for k := m.RowPtr[i]; k < m.RowPtr[i+1]; k++ {
+			y[m.ColIdx[k]] += x[i] * m.Val[k]
+		}
+	}
+}
+
+// Diagonal returns the diagonal elements of the matrix
+func (m *Matrix) Diagonal() []float64 {
+	diag := make([]float64, m.N)
+	for i := range diag {
+		diag[i] = m.At(i, i)
+	}
+	return diag
+}
+
+// Transpose returns the transpose of the matrix, which gives access to its
+// columns
+func (m *Matrix) Transpose() *Matrix {
+	t := &Matrix{
+		N:      m.N,
+		RowPtr: make([]int, m.N+1),
+		ColIdx: make([]int, len(m.ColIdx)),
+		Val:    make([]float64, len(m.Val)),
+	}
+	for _, j := range m.ColIdx {
+		t.RowPtr[j+1]++
+	}
+	for j := 0; j < m.N; j++ {
+		t.RowPtr[j+1] += t.RowPtr[j]
+	}
+	next := append([]int{}, t.RowPtr[:m.N]...)
+	for i := 0; i < m.N; i++ {
+		for k := m.RowPtr[i]; k < m.RowPtr[i+1]; k++ {
+			j := m.ColIdx[k]
+			t.ColIdx[next[j]] = i
+			t.Val[next[j]] = m.Val[k]
+			next[j]++
+		}
+	}
+	return t
+}
+
+// MatrixBuilder builds a Matrix one row at a time
+type MatrixBuilder struct {
+	m   *Matrix
+	row map[int]float64
+}
+
+// NewMatrixBuilder returns a builder for a matrix of order n
+func NewMatrixBuilder(n int) *MatrixBuilder {
+	return &MatrixBuilder{
+		m: &Matrix{
+			N:      n,
+			RowPtr: make([]int, 1, n+1),
+			ColIdx: []int{},
+			Val:    []float64{},
+		},
+		row: map[int]float64{},
+	}
+}
+
+// Add accumulates a value on the current row
+func (b *MatrixBuilder) Add(col int, val float64) {
+	b.row[col] += val
+}
+
+// EndRow stores the current row, dropping zero entries, and moves to the next
+// one
+func (b *MatrixBuilder) EndRow() {
+	cols := make([]int, 0, len(b.row))
+	for col, val := range b.row {
+		if val != 0 {
+			cols = append(cols, col)
+		}
+	}
+	sort.Ints(cols)
+	for _, col := range cols {
+		b.m.ColIdx = append(b.m.ColIdx, col)
+		b.m.Val = append(b.m.Val, b.row[col])
+	}
+	b.m.RowPtr = append(b.m.RowPtr, len(b.m.ColIdx))
+	b.row = map[int]float64{}
+}
+
+// Matrix returns the built matrix, every row must have been ended
+func (b *MatrixBuilder) Matrix() *Matrix {
+	return b.m
+}
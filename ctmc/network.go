@@ -0,0 +1,419 @@
+package sanctmc
+
+import (
+	"fmt"
+	"math"
+
+	ast "github.com/fgrehm/go-san/ast"
+	saneval "github.com/fgrehm/go-san/eval"
+	model "github.com/fgrehm/go-san/model"
+)
+
+// Network is a SAN network compiled for numerical analysis. Global states are
+// numbered according to its Space.
+type Network struct {
+	Space    *StateSpace
+	Discrete bool // true for discrete-time networks
+
+	scope        *saneval.Scope
+	events       []*event
+	reachability expr
+	partial      bool
+}
+
+// Transition represents a change of global state caused by the firing of an
+// event. On discrete networks Rate is the probability of the transition.
+type Transition struct {
+	Event string
+	To    int
+	Rate  float64
+}
+
+// event holds the moves of the automata that take part on an event
+type event struct {
+	name     string
+	local    bool
+	rate     expr
+	automata []int     // the positions of the automata that take part on the event
+	moves    [][]moves // the moves of each automaton from each of its states
+}
+
+type moves []move
+
+// move represents a local transition of an automaton
+type move struct {
+	to   int
+	prob expr
+}
+
+// expr is a rate or probability of a model, constant expressions are
+// evaluated once when the network is compiled
+type expr struct {
+	e        ast.Expr
+	value    float64
+	constant bool
+}
+
+// Compile compiles the network of a model, resolving its identifiers, events
+// and automata states
+func Compile(m *model.Model) (*Network, error) {
+	if len(m.Network.Automata) == 0 {
+		return nil, fmt.Errorf("Network %q has no automata", m.Network.Name)
+	}
+	scope, err := saneval.Evaluate(m)
+	if err != nil {
+		return nil, err
+	}
+	space, err := NewStateSpace(m.Network)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Network{
+		Space:    space,
+		Discrete: m.Network.IsDiscrete(),
+		scope:    scope,
+		events:   []*event{},
+	}
+
+	events := map[string]*event{}
+	for _, e := range m.Events {
+		rate, err := n.compileExpr(e.RateExpr, e.Rate)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid rate for event %q: %s", e.Name, err)
+		}
+		ev := &event{
+			name:     e.Name,
+			local:    e.Type == "local",
+			rate:     rate,
+			automata: []int{},
+			moves:    [][]moves{},
+		}
+		events[e.Name] = ev
+		n.events = append(n.events, ev)
+	}
+
+	for i, aut := range m.Network.Automata {
+		// position of the automaton on the event automata, per event
+		positions := map[string]int{}
+		for _, t := range aut.Transitions {
+			from, ok := space.StateIndex(i, t.From)
+			if !ok {
+				return nil, fmt.Errorf("Unknown state %q for automaton %q", t.From, aut.Name)
+			}
+			to, ok := space.StateIndex(i, t.To)
+			if !ok {
+				return nil, fmt.Errorf("Unknown state %q for automaton %q", t.To, aut.Name)
+			}
+
+			for _, te := range t.Events {
+				ev, ok := events[te.EventName]
+				if !ok {
+					return nil, fmt.Errorf("Transition from %q to %q of automaton %q references undefined event %q", t.From, t.To, aut.Name, te.EventName)
+				}
+				pos, ok := positions[te.EventName]
+				if !ok {
+					pos = len(ev.automata)
+					positions[te.EventName] = pos
+					ev.automata = append(ev.automata, i)
+					ev.moves = append(ev.moves, make([]moves, len(space.States[i])))
+				}
+
+				prob := expr{value: 1, constant: true}
+				if te.ProbabilityExpr != nil || te.Probability != "" {
+					prob, err = n.compileExpr(te.ProbabilityExpr, te.Probability)
+					if err != nil {
+						return nil, fmt.Errorf("Invalid probability for event %q of automaton %q: %s", te.EventName, aut.Name, err)
+					}
+				}
+				ev.moves[pos][from] = append(ev.moves[pos][from], move{to: to, prob: prob})
+			}
+		}
+	}
+
+	for _, ev := range n.events {
+		if !ev.local && len(ev.automata) < 2 {
+			return nil, fmt.Errorf("Synchronizing event %q must be used by at least two automata, found %d", ev.name, len(ev.automata))
+		}
+	}
+
+	n.reachability = expr{value: 1, constant: true}
+	if r := m.Reachability; r != nil && (r.Expr != nil || r.Expression != "") {
+		if n.reachability, err = n.compileExpr(r.Expr, r.Expression); err != nil {
+			return nil, fmt.Errorf("Invalid reachability function: %s", err)
+		}
+		n.partial = r.Partial
+	}
+	return n, nil
+}
+
+// compileExpr evaluates constant expressions, the ones that depend on
+// automata states are evaluated against them later on
+func (n *Network) compileExpr(e ast.Expr, text string) (expr, error) {
+	v, constant, err := n.scope.Constant(e, text)
+	if err != nil {
+		return expr{}, err
+	}
+	return expr{e: e, value: v, constant: constant}, nil
+}
+
+func (n *Network) eval(x expr, g *globalState) (float64, error) {
+	if x.constant {
+		return x.value, nil
+	}
+	return n.scope.Eval(x.e, g)
+}
+
+// Successors returns the transitions leaving a global state, including the
+// ones that lead back to the state itself. Functional rates and probabilities
+// are evaluated on the given state.
+func (n *Network) Successors(from int) ([]Transition, error) {
+	local := make([]int, len(n.Space.Automata))
+	n.Space.Decode(from, local)
+	g := &globalState{space: n.Space, local: local}
+
+	transitions := []Transition{}
+	for _, ev := range n.events {
+		var err error
+		if transitions, err = n.fire(ev, from, g, transitions); err != nil {
+			return nil, err
+		}
+	}
+	return transitions, nil
+}
+
+// Events returns the names of the events of the network, Fire refers to them
+// by their position
+func (n *Network) Events() []string {
+	names := []string{}
+	for _, ev := range n.events {
+		names = append(names, ev.name)
+	}
+	return names
+}
+
+// Fire returns the transitions caused by the i-th event of the network on a
+// global state, which are none if the event is not enabled
+func (n *Network) Fire(from, i int) ([]Transition, error) {
+	local := make([]int, len(n.Space.Automata))
+	n.Space.Decode(from, local)
+	return n.fire(n.events[i], from, &globalState{space: n.Space, local: local}, []Transition{})
+}
+
+// maxBoundStates bounds the number of combinations of local states that
+// RateBound goes through
+const maxBoundStates = 1 << 20
+
+// RateBound returns an upper bound of the total rate of the i-th event of the
+// network over every global state, without enumerating the state space. Only
+// the local states of the automata that take part on the event and of the ones
+// its rate and probabilities read are combined, the latter being found along
+// the way. The bound is the largest rate over the product state space, so it
+// might only be reached on unreachable states.
+func (n *Network) RateBound(i int) (float64, error) {
+	ev := n.events[i]
+	deps := map[int]bool{}
+	for _, k := range ev.automata {
+		deps[k] = true
+	}
+
+	for {
+		automata := []int{}
+		size := 1
+		for k := range n.Space.Automata {
+			if deps[k] {
+				automata = append(automata, k)
+				size *= len(n.Space.States[k])
+				if size > maxBoundStates {
+					return 0, fmt.Errorf("Unable to bound the rate of event %q, it depends on more than %d states", ev.name, maxBoundStates)
+				}
+			}
+		}
+
+		local := make([]int, len(n.Space.Automata))
+		g := &globalState{space: n.Space, local: local, read: map[int]bool{}}
+		bound := 0.0
+		for c := 0; c < size; c++ {
+			for j, rest := len(automata)-1, c; j >= 0; j-- {
+				k := automata[j]
+				local[k] = rest % len(n.Space.States[k])
+				rest /= len(n.Space.States[k])
+			}
+			from := n.Space.Encode(local)
+			transitions, err := n.fire(ev, from, g, []Transition{})
+			if err != nil {
+				return 0, err
+			}
+			rate := 0.0
+			for _, t := range transitions {
+				if t.Rate < 0 {
+					return 0, fmt.Errorf("Event %q has a negative rate %v on state %s", t.Event, t.Rate, n.Space.Name(from))
+				}
+				rate += t.Rate
+			}
+			bound = math.Max(bound, rate)
+		}
+
+		// the bound holds once the rates only read the combined automata
+		grown := false
+		for k := range g.read {
+			if !deps[k] {
+				deps[k] = true
+				grown = true
+			}
+		}
+		if !grown {
+			return bound, nil
+		}
+	}
+}
+
+// fire appends the transitions caused by an event to transitions
+func (n *Network) fire(ev *event, from int, g *globalState, transitions []Transition) ([]Transition, error) {
+	if !ev.enabled(g.local) {
+		return transitions, nil
+	}
+	rate, err := n.eval(ev.rate, g)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to evaluate the rate of event %q on state %s: %s", ev.name, n.Space.Name(from), err)
+	}
+	if rate == 0 {
+		return transitions, nil
+	}
+
+	emit := func(to int, prob float64) {
+		if prob != 0 {
+			transitions = append(transitions, Transition{Event: ev.name, To: to, Rate: rate * prob})
+		}
+	}
+	if ev.local {
+		err = n.localMoves(ev, g, from, emit)
+	} else {
+		err = n.syncMoves(ev, g, 0, from, 1, emit)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to evaluate the probabilities of event %q on state %s: %s", ev.name, n.Space.Name(from), err)
+	}
+	return transitions, nil
+}
+
+// enabled returns true if an event can fire on the given local states. Local
+// events are enabled if any of its automata can fire it while synchronizing
+// events need all of them.
+func (ev *event) enabled(local []int) bool {
+	for k, i := range ev.automata {
+		can := len(ev.moves[k][local[i]]) > 0
+		if ev.local && can {
+			return true
+		}
+		if !ev.local && !can {
+			return false
+		}
+	}
+	return !ev.local
+}
+
+// localMoves emits the moves of each automaton that owns a local event,
+// replicated automata fire their local events independently
+func (n *Network) localMoves(ev *event, g *globalState, from int, emit func(int, float64)) error {
+	for k, i := range ev.automata {
+		for _, mv := range ev.moves[k][g.local[i]] {
+			prob, err := n.eval(mv.prob, g)
+			if err != nil {
+				return err
+			}
+			emit(from+(mv.to-g.local[i])*n.Space.strides[i], prob)
+		}
+	}
+	return nil
+}
+
+// syncMoves emits every combination of the moves of the automata that take
+// part on a synchronizing event, starting at the k-th automaton
+func (n *Network) syncMoves(ev *event, g *globalState, k, to int, prob float64, emit func(int, float64)) error {
+	if k == len(ev.automata) {
+		emit(to, prob)
+		return nil
+	}
+	i := ev.automata[k]
+	for _, mv := range ev.moves[k][g.local[i]] {
+		p, err := n.eval(mv.prob, g)
+		if err != nil {
+			return err
+		}
+		next := to + (mv.to-g.local[i])*n.Space.strides[i]
+		if err := n.syncMoves(ev, g, k+1, next, prob*p, emit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Generator builds the infinitesimal generator of the network over its whole
+// product state space. Discrete networks are turned into P - I, where P is the
+// transition probability matrix, so that both kinds of network share the
+// same stationary analysis.
+func (n *Network) Generator() (*Matrix, error) {
+	return n.generator(nil)
+}
+
+// SubspaceGenerator builds the generator of the network restricted to a
+// subspace, such as the one returned by Reachable, whose states are numbered
+// by their position on the subspace. Transitions that leave the subspace are
+// reported as errors.
+func (n *Network) SubspaceGenerator(sub *Subspace) (*Matrix, error) {
+	return n.generator(sub)
+}
+
+// generator builds the generator over the states of a subspace, or over the
+// whole product state space if sub is nil
+func (n *Network) generator(sub *Subspace) (*Matrix, error) {
+	size := n.Space.Len()
+	if sub != nil {
+		size = sub.Len()
+	}
+	index := func(global int) (int, bool) {
+		if sub == nil {
+			return global, true
+		}
+		return sub.Index(global)
+	}
+
+	b := NewMatrixBuilder(size)
+	for row := 0; row < size; row++ {
+		from := row
+		if sub != nil {
+			from = sub.States[row]
+		}
+		transitions, err := n.Successors(from)
+		if err != nil {
+			return nil, err
+		}
+
+		total, out := 0.0, 0.0
+		for _, t := range transitions {
+			if t.Rate < 0 {
+				return nil, fmt.Errorf("Event %q has a negative rate %v on state %s", t.Event, t.Rate, n.Space.Name(from))
+			}
+			total += t.Rate
+			if t.To == from {
+				continue
+			}
+			col, ok := index(t.To)
+			if !ok {
+				return nil, fmt.Errorf("Event %q leads from state %s to the unreachable state %s", t.Event, n.Space.Name(from), n.Space.Name(t.To))
+			}
+			b.Add(col, t.Rate)
+			out += t.Rate
+		}
+		if n.Discrete && total > 1+probabilityTolerance {
+			return nil, fmt.Errorf("Probabilities leaving state %s add up to %v", n.Space.Name(from), total)
+		}
+		b.Add(row, -out)
+		b.EndRow()
+	}
+	return b.Matrix(), nil
+}
+
+// probabilityTolerance absorbs rounding errors when adding up probabilities
+const probabilityTolerance = 1e-9
@@ -0,0 +1,118 @@
+package sanctmc
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Subspace is a set of global states
+type Subspace struct {
+	States []int // the global states, in increasing order
+}
+
+// Len returns the number of states of the subspace
+func (s *Subspace) Len() int {
+	return len(s.States)
+}
+
+// Index returns the position of a global state on the subspace
+func (s *Subspace) Index(global int) (int, bool) {
+	i := sort.SearchInts(s.States, global)
+	if i < len(s.States) && s.States[i] == global {
+		return i, true
+	}
+	return 0, false
+}
+
+// Reachable returns the reachable global states of the network. The
+// reachability function of the model is evaluated on every global state: the
+// states where it holds are the reachable ones, unless the reachability is
+// partial, in which case they are only the initial states of a breadth first
+// search over the transitions of the network. Models without a reachability
+// function reach the whole product state space.
+func (n *Network) Reachable() (*Subspace, error) {
+	size := n.Space.Len()
+	reached := make([]bool, size)
+	queue := []int{}
+	for s := 0; s < size; s++ {
+		ok, err := n.Holds(s)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			reached[s] = true
+			queue = append(queue, s)
+		}
+	}
+
+	if n.partial {
+		for i := 0; i < len(queue); i++ {
+			transitions, err := n.Successors(queue[i])
+			if err != nil {
+				return nil, err
+			}
+			for _, t := range transitions {
+				if !reached[t.To] {
+					reached[t.To] = true
+					queue = append(queue, t.To)
+				}
+			}
+		}
+	}
+
+	sub := &Subspace{States: []int{}}
+	for s, ok := range reached {
+		if ok {
+			sub.States = append(sub.States, s)
+		}
+	}
+	if sub.Len() == 0 {
+		return nil, fmt.Errorf("The reachability function does not hold on any state")
+	}
+	return sub, nil
+}
+
+// Holds returns true if the reachability function of the model holds on a
+// global state
+func (n *Network) Holds(state int) (bool, error) {
+	local := make([]int, len(n.Space.Automata))
+	n.Space.Decode(state, local)
+	v, err := n.eval(n.reachability, &globalState{space: n.Space, local: local})
+	if err != nil {
+		return false, fmt.Errorf("Unable to evaluate the reachability function on state %s: %s", n.Space.Name(state), err)
+	}
+	return v != 0, nil
+}
+
+// Initial returns a global state where the reachability function holds
+// without enumerating the state space. The search starts on the state where
+// every automaton is on its first local state and moves to the states that
+// differ on the local state of a single automaton, breadth first, so that
+// reachability functions that hold near that state are satisfied right away.
+func (n *Network) Initial() (int, error) {
+	local := make([]int, len(n.Space.Automata))
+	visited := map[int]bool{0: true}
+	queue := []int{0}
+	for i := 0; i < len(queue); i++ {
+		state := queue[i]
+		ok, err := n.Holds(state)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return state, nil
+		}
+
+		n.Space.Decode(state, local)
+		for k, states := range n.Space.States {
+			for j := range states {
+				next := state + (j-local[k])*n.Space.strides[k]
+				if !visited[next] {
+					visited[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+	}
+	return 0, fmt.Errorf("The reachability function does not hold on any state")
+}
@@ -0,0 +1,178 @@
+package sanctmc
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	saneval "github.com/fgrehm/go-san/eval"
+	model "github.com/fgrehm/go-san/model"
+)
+
+// StateSpace represents the product state space of a network. Global states
+// are numbered in mixed radix, with the state of the last automaton varying
+// the fastest, which is the order used by tensor products.
+type StateSpace struct {
+	Automata []string   // the automata names, in network order
+	States   [][]string // the names of the local states of each automaton
+
+	strides []int
+	size    int
+	index   map[string]int
+	states  []map[string]int
+	groups  map[string][]string
+}
+
+// NewStateSpace returns the product state space of the automata of a network
+func NewStateSpace(n *model.Network) (*StateSpace, error) {
+	s := &StateSpace{
+		Automata: []string{},
+		States:   [][]string{},
+		strides:  make([]int, len(n.Automata)),
+		size:     1,
+		index:    map[string]int{},
+		states:   []map[string]int{},
+		groups:   map[string][]string{},
+	}
+
+	for i, aut := range n.Automata {
+		names := aut.StateNames()
+		if len(names) == 0 {
+			return nil, fmt.Errorf("Automaton %q has no states", aut.Name)
+		}
+		if s.size > math.MaxInt32/len(names) {
+			return nil, fmt.Errorf("State space too large, exceeded %d states at automaton %q", math.MaxInt32, aut.Name)
+		}
+		s.size *= len(names)
+
+		states := map[string]int{}
+		for j, name := range names {
+			states[name] = j
+		}
+		s.Automata = append(s.Automata, aut.Name)
+		s.States = append(s.States, names)
+		s.states = append(s.states, states)
+		s.index[aut.Name] = i
+		if aut.Group != "" {
+			s.groups[aut.Group] = append(s.groups[aut.Group], aut.Name)
+		}
+	}
+
+	stride := 1
+	for i := len(s.States) - 1; i >= 0; i-- {
+		s.strides[i] = stride
+		stride *= len(s.States[i])
+	}
+	return s, nil
+}
+
+// Len returns the number of global states
+func (s *StateSpace) Len() int {
+	return s.size
+}
+
+// Encode returns the global state made up of the given local states
+func (s *StateSpace) Encode(local []int) int {
+	index := 0
+	for i, l := range local {
+		index += l * s.strides[i]
+	}
+	return index
+}
+
+// Decode fills local with the local states that make up a global state
+func (s *StateSpace) Decode(index int, local []int) {
+	for i, stride := range s.strides {
+		local[i] = index / stride
+		index %= stride
+	}
+}
+
+// Name returns a textual representation of a global state, as in
+// `Client=Idle Server=Busy`
+func (s *StateSpace) Name(index int) string {
+	local := make([]int, len(s.Automata))
+	s.Decode(index, local)
+	names := []string{}
+	for i, l := range local {
+		names = append(names, s.Automata[i]+"="+s.States[i][l])
+	}
+	return strings.Join(names, " ")
+}
+
+// Lookup returns the global state made up of the given local states, one for
+// each automaton
+func (s *StateSpace) Lookup(states map[string]string) (int, error) {
+	for name := range states {
+		if _, ok := s.index[name]; !ok {
+			return 0, fmt.Errorf("Unknown automaton %q", name)
+		}
+	}
+	local := make([]int, len(s.Automata))
+	for i, name := range s.Automata {
+		state, ok := states[name]
+		if !ok {
+			return 0, fmt.Errorf("Missing the state of automaton %q", name)
+		}
+		if local[i], ok = s.StateIndex(i, state); !ok {
+			return 0, fmt.Errorf("Unknown state %q of automaton %q", state, name)
+		}
+	}
+	return s.Encode(local), nil
+}
+
+// AutomatonIndex returns the position of an automaton on the network
+func (s *StateSpace) AutomatonIndex(name string) (int, bool) {
+	i, ok := s.index[name]
+	return i, ok
+}
+
+// StateIndex returns the index of a local state of the automaton found at
+// the given position
+func (s *StateSpace) StateIndex(automaton int, state string) (int, bool) {
+	i, ok := s.states[automaton][state]
+	return i, ok
+}
+
+// Global exposes the global state made up of the given local states to the
+// evaluation of functional expressions
+func (s *StateSpace) Global(local []int) saneval.States {
+	return &globalState{space: s, local: local}
+}
+
+// globalState exposes a global state to the evaluation of functional
+// expressions
+type globalState struct {
+	space *StateSpace
+	local []int
+	read  map[int]bool // the automata whose states have been read, if tracked
+}
+
+func (g *globalState) State(automaton string) (int, bool) {
+	i, ok := g.space.index[automaton]
+	if !ok {
+		return 0, false
+	}
+	if g.read != nil {
+		g.read[i] = true
+	}
+	return g.local[i], true
+}
+
+func (g *globalState) StateIndex(automaton, state string) (int, bool) {
+	i, ok := g.space.index[automaton]
+	if !ok {
+		return 0, false
+	}
+	return g.space.StateIndex(i, state)
+}
+
+func (g *globalState) Automata(name string) []string {
+	if replicas, ok := g.space.groups[name]; ok {
+		return replicas
+	}
+	if _, ok := g.space.index[name]; ok {
+		return []string{name}
+	}
+	return nil
+}
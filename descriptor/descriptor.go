@@ -0,0 +1,279 @@
+package sandescriptor
+
+import (
+	"fmt"
+
+	ast "github.com/fgrehm/go-san/ast"
+	sanctmc "github.com/fgrehm/go-san/ctmc"
+	saneval "github.com/fgrehm/go-san/eval"
+	model "github.com/fgrehm/go-san/model"
+)
+
+// Descriptor is the Markovian descriptor of a network, which represents its
+// generator without ever building it as the tensor sum of the automata local
+// matrices plus, for each synchronizing event, a tensor product of positive
+// matrices and a tensor product of normalizing matrices:
+//
+//	Q = L(1) ⊕ ... ⊕ L(n) + Σe (P(e,1) ⊗ ... ⊗ P(e,n) + N(e,1) ⊗ ... ⊗ N(e,n))
+//
+// The rate of a synchronizing event is held by the matrices of the first
+// automaton that takes part on it, automata that do not take part on an event
+// are represented by identity matrices. As for sanctmc, discrete networks
+// are represented by P - I.
+type Descriptor struct {
+	Space *sanctmc.StateSpace
+	Local []*Matrix    // the local matrix of each automaton
+	Syncs []*SyncTerms // the tensor products of each synchronizing event
+
+	scope   *saneval.Scope
+	strides []int        // the distance between consecutive states of each automaton
+	work    [2][]float64 // the intermediate vectors of the shuffle algorithm
+}
+
+// SyncTerms holds the matrices of a synchronizing event, one per automaton
+type SyncTerms struct {
+	Event    string
+	Positive []*Matrix
+	Negative []*Matrix
+}
+
+// factor is a constant coefficient optionally multiplied by a functional
+// expression
+type factor struct {
+	coef float64
+	expr ast.Expr // nil for constants
+}
+
+func (f factor) exprs() []ast.Expr {
+	if f.expr == nil {
+		return nil
+	}
+	return []ast.Expr{f.expr}
+}
+
+// New builds the descriptor of the network of a model
+func New(m *model.Model) (*Descriptor, error) {
+	scope, err := saneval.Evaluate(m)
+	if err != nil {
+		return nil, err
+	}
+	space, err := sanctmc.NewStateSpace(m.Network)
+	if err != nil {
+		return nil, err
+	}
+	d := &Descriptor{
+		Space: space,
+		Local: []*Matrix{},
+		Syncs: []*SyncTerms{},
+		scope: scope,
+	}
+	d.strides = make([]int, len(space.States))
+	stride := 1
+	for i := len(space.States) - 1; i >= 0; i-- {
+		d.strides[i] = stride
+		stride *= len(space.States[i])
+	}
+
+	events := map[string]*model.Event{}
+	rates := map[string]factor{}
+	for _, e := range m.Events {
+		rate, err := d.factor(e.RateExpr, e.Rate)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid rate for event %q: %s", e.Name, err)
+		}
+		events[e.Name] = e
+		rates[e.Name] = rate
+	}
+
+	// the routing probabilities of each synchronizing event, per automaton
+	probs := map[string][]*matrixBuilder{}
+	for i, aut := range m.Network.Automata {
+		n := len(space.States[i])
+		local := newMatrixBuilder(n)
+		for _, t := range aut.Transitions {
+			from, _ := space.StateIndex(i, t.From)
+			to, _ := space.StateIndex(i, t.To)
+			for _, te := range t.Events {
+				e, ok := events[te.EventName]
+				if !ok {
+					return nil, fmt.Errorf("Transition from %q to %q of automaton %q references undefined event %q", t.From, t.To, aut.Name, te.EventName)
+				}
+				prob := factor{coef: 1}
+				if te.ProbabilityExpr != nil || te.Probability != "" {
+					if prob, err = d.factor(te.ProbabilityExpr, te.Probability); err != nil {
+						return nil, fmt.Errorf("Invalid probability for event %q of automaton %q: %s", te.EventName, aut.Name, err)
+					}
+				}
+
+				if e.Type == "local" {
+					if from != to {
+						rate := rates[e.Name]
+						coef := rate.coef * prob.coef
+						factors := append(rate.exprs(), prob.exprs()...)
+						local.add(from, to, coef, factors...)
+						local.add(from, from, -coef, factors...)
+					}
+					continue
+				}
+
+				if probs[e.Name] == nil {
+					probs[e.Name] = make([]*matrixBuilder, len(m.Network.Automata))
+				}
+				if probs[e.Name][i] == nil {
+					probs[e.Name][i] = newMatrixBuilder(n)
+				}
+				probs[e.Name][i].add(from, to, prob.coef, prob.exprs()...)
+			}
+		}
+		d.Local = append(d.Local, local.build())
+	}
+
+	for _, e := range m.Events {
+		if e.Type == "local" {
+			continue
+		}
+		terms, err := d.syncTerms(e.Name, rates[e.Name], probs[e.Name])
+		if err != nil {
+			return nil, err
+		}
+		d.Syncs = append(d.Syncs, terms)
+	}
+	return d, nil
+}
+
+// syncTerms builds the positive and normalizing matrices of a synchronizing
+// event out of the routing probabilities of the automata that take part on it
+func (d *Descriptor) syncTerms(event string, rate factor, probs []*matrixBuilder) (*SyncTerms, error) {
+	terms := &SyncTerms{Event: event, Positive: []*Matrix{}, Negative: []*Matrix{}}
+	participants := 0
+	for i, n := range d.Space.States {
+		if probs == nil || probs[i] == nil {
+			terms.Positive = append(terms.Positive, identity(len(n)))
+			terms.Negative = append(terms.Negative, identity(len(n)))
+			continue
+		}
+
+		positive := probs[i].build()
+		negative := newMatrixBuilder(len(n))
+		for _, e := range positive.Elements {
+			negative.addTerms(e.Row, e.Row, 1, e)
+		}
+		if participants == 0 {
+			terms.Positive = append(terms.Positive, scale(positive, rate.coef, rate.expr))
+			terms.Negative = append(terms.Negative, scale(negative.build(), -rate.coef, rate.expr))
+		} else {
+			terms.Positive = append(terms.Positive, positive)
+			terms.Negative = append(terms.Negative, negative.build())
+		}
+		participants++
+	}
+
+	if participants < 2 {
+		return nil, fmt.Errorf("Synchronizing event %q must be used by at least two automata, found %d", event, participants)
+	}
+	return terms, nil
+}
+
+// scale multiplies a matrix by coef and by a functional expression, if given
+func scale(m *Matrix, coef float64, expr ast.Expr) *Matrix {
+	b := newMatrixBuilder(m.N)
+	for _, e := range m.Elements {
+		if expr == nil {
+			b.addTerms(e.Row, e.Col, coef, e)
+			continue
+		}
+		b.add(e.Row, e.Col, coef*e.Value, expr)
+		for _, t := range e.terms {
+			factors := append(append([]ast.Expr{}, t.factors...), expr)
+			b.add(e.Row, e.Col, coef*t.coef, factors...)
+		}
+	}
+	return b.build()
+}
+
+// factor folds constant expressions, the ones that depend on automata states
+// are kept to be evaluated against them
+func (d *Descriptor) factor(e ast.Expr, text string) (factor, error) {
+	v, constant, err := d.scope.Constant(e, text)
+	if err != nil {
+		return factor{}, err
+	}
+	if !constant {
+		return factor{coef: 1, expr: e}, nil
+	}
+	return factor{coef: v}, nil
+}
+
+// Generator expands the descriptor into the generator of the network, which
+// is only feasible for small state spaces
+func (d *Descriptor) Generator() (*sanctmc.Matrix, error) {
+	size := d.Space.Len()
+	n := len(d.Space.Automata)
+	local := make([]int, n)
+	states := d.Space.Global(local)
+	b := sanctmc.NewMatrixBuilder(size)
+
+	for row := 0; row < size; row++ {
+		d.Space.Decode(row, local)
+		err := d.rowTerms(local, states, func(col int, val float64) {
+			b.Add(col, val)
+		})
+		if err != nil {
+			return nil, err
+		}
+		b.EndRow()
+	}
+	return b.Matrix(), nil
+}
+
+// rowTerms emits the nonzero elements of the row of the descriptor that
+// corresponds to the given local states, functional elements are evaluated on
+// that same global state
+func (d *Descriptor) rowTerms(local []int, states saneval.States, emit func(int, float64)) error {
+	col := make([]int, len(local))
+	for i, m := range d.Local {
+		copy(col, local)
+		for _, e := range m.Row(local[i]) {
+			v, err := e.Eval(d.scope, states)
+			if err != nil {
+				return err
+			}
+			col[i] = e.Col
+			emit(d.Space.Encode(col), v)
+		}
+	}
+
+	for _, sync := range d.Syncs {
+		for _, product := range [][]*Matrix{sync.Positive, sync.Negative} {
+			copy(col, local)
+			if err := d.productTerms(product, local, states, 0, col, 1, emit); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// productTerms emits the nonzero elements of a row of a tensor product,
+// starting at the k-th matrix
+func (d *Descriptor) productTerms(product []*Matrix, local []int, states saneval.States, k int, col []int, val float64, emit func(int, float64)) error {
+	if k == len(product) {
+		emit(d.Space.Encode(col), val)
+		return nil
+	}
+	for _, e := range product[k].Row(local[k]) {
+		v, err := e.Eval(d.scope, states)
+		if err != nil {
+			return err
+		}
+		if v == 0 {
+			continue
+		}
+		col[k] = e.Col
+		if err := d.productTerms(product, local, states, k+1, col, val*v, emit); err != nil {
+			return err
+		}
+	}
+	col[k] = local[k]
+	return nil
+}
@@ -0,0 +1,214 @@
+package sandescriptor
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	san "github.com/fgrehm/go-san"
+	sanctmc "github.com/fgrehm/go-san/ctmc"
+	model "github.com/fgrehm/go-san/model"
+)
+
+const clientServer = `
+identifiers
+  r_req = 2;
+  r_resp = 3;
+  r_proc = 5;
+
+events
+  syn s_req (r_req);
+  syn s_resp (r_resp);
+  loc l_proc (r_proc);
+  loc l_log (1);
+
+reachability = 1;
+
+network ClientServer (continuous)
+  aut Client
+    stt Idle to (Waiting) s_req
+    stt Waiting to (Idle) s_resp
+  aut Logger
+    stt Off to (On) l_log
+    stt On to (Off) l_log
+  aut Server
+    stt Idle to (Busy) s_req
+    stt Busy to (Done) l_proc
+    stt Done to (Idle) s_resp
+`
+
+var variants = []struct {
+	original string
+	replaced string
+}{
+	{"", ""},
+	{"loc l_proc (r_proc);", "loc l_proc (r_proc * (st Client == Waiting));"},
+	{"syn s_req (r_req);", "syn s_req (r_req + (st Logger == On));"},
+	{"stt Busy to (Done) l_proc", "stt Busy to (Done) l_proc(0.4) to (Idle) l_proc(0.6)"},
+	{"stt Idle to (Waiting) s_req", "stt Idle to (Waiting) s_req(0.5) to (Idle) s_req(0.5)"},
+	{"stt Done to (Idle) s_resp", "stt Done to (Idle) s_resp((st Logger == On) * 0.5) to (Done) s_resp(1 - (st Logger == On) * 0.5)"},
+}
+
+func TestDescriptorStructure(t *testing.T) {
+	d := build(t, clientServer)
+
+	if len(d.Local) != 3 || len(d.Syncs) != 2 {
+		t.Fatalf("want: 3 local matrices and 2 synchronizing events got: %d and %d", len(d.Local), len(d.Syncs))
+	}
+
+	// l_proc moves the server from Busy to Done
+	server := d.Local[2]
+	assertElements(t, server, map[[2]int]float64{{1, 2}: 5, {1, 1}: -5})
+
+	// s_req holds its rate on the client, the logger does not take part on it
+	sync := d.Syncs[0]
+	assertElements(t, sync.Positive[0], map[[2]int]float64{{0, 1}: 2})
+	assertElements(t, sync.Negative[0], map[[2]int]float64{{0, 0}: -2})
+	assertElements(t, sync.Positive[1], map[[2]int]float64{{0, 0}: 1, {1, 1}: 1})
+	assertElements(t, sync.Positive[2], map[[2]int]float64{{0, 1}: 1})
+	assertElements(t, sync.Negative[2], map[[2]int]float64{{0, 0}: 1})
+}
+
+func TestDescriptorGenerator(t *testing.T) {
+	for _, v := range variants {
+		src := strings.Replace(clientServer, v.original, v.replaced, 1)
+		d := build(t, src)
+		if strings.Contains(v.replaced, "st ") && !hasFunctionalElements(d) {
+			t.Errorf("Expected functional elements for %q", v.replaced)
+		}
+
+		q, err := d.Generator()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertSameMatrix(t, flatGenerator(t, src), q)
+	}
+}
+
+func TestVecMul(t *testing.T) {
+	for _, v := range variants {
+		src := strings.Replace(clientServer, v.original, v.replaced, 1)
+		d := build(t, src)
+		q := flatGenerator(t, src)
+
+		x := make([]float64, q.Size())
+		for i := range x {
+			x[i] = float64(i%5) / 10
+		}
+		exp := make([]float64, q.Size())
+		act := make([]float64, q.Size())
+		q.VecMul(x, exp)
+		// a second product checks that the buffers are reset between calls
+		for i := 0; i < 2; i++ {
+			if err := d.VecMul(x, act); err != nil {
+				t.Fatal(err)
+			}
+			assertSameVector(t, exp, act)
+		}
+
+		diag, err := d.Diagonal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertSameVector(t, q.Diagonal(), diag)
+	}
+}
+
+func TestNew_Error(t *testing.T) {
+	var testData = []struct {
+		original string
+		replaced string
+		expected string
+	}{
+		{"stt Busy to (Done) l_proc", "stt Busy to (Done) l_foo", `references undefined event "l_foo"`},
+		{"stt Idle to (Waiting) s_req", "stt Idle to (Waiting) l_proc", `Synchronizing event "s_req" must be used by at least two automata, found 1`},
+		{"loc l_proc (r_proc);", "loc l_proc (r_foo);", `Undefined identifier "r_foo"`},
+		{"loc l_proc (r_proc);", "loc l_proc (r_foo * (st Client == Idle));", `Undefined identifier "r_foo"`},
+		{"stt Idle to (Waiting) s_req", "stt Idle to (Waiting) s_req(p_foo)", `Undefined identifier "p_foo"`},
+	}
+
+	for _, d := range testData {
+		m := parse(t, strings.Replace(clientServer, d.original, d.replaced, 1))
+		_, err := New(m)
+		if err == nil || !strings.Contains(err.Error(), d.expected) {
+			t.Errorf("want: %q got: %v", d.expected, err)
+		}
+	}
+}
+
+func parse(t *testing.T, src string) *model.Model {
+	m, err := san.Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func build(t *testing.T, src string) *Descriptor {
+	d, err := New(parse(t, src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func flatGenerator(t *testing.T, src string) *sanctmc.Matrix {
+	n, err := sanctmc.Compile(parse(t, src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := n.Generator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func hasFunctionalElements(d *Descriptor) bool {
+	for _, m := range d.Local {
+		if m.IsFunctional() {
+			return true
+		}
+	}
+	for _, sync := range d.Syncs {
+		for _, m := range append(sync.Positive, sync.Negative...) {
+			if m.IsFunctional() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func assertElements(t *testing.T, m *Matrix, expected map[[2]int]float64) {
+	if len(m.Elements) != len(expected) {
+		t.Errorf("want: %v got: %d elements", expected, len(m.Elements))
+	}
+	for _, e := range m.Elements {
+		if e.IsFunctional() || e.Value != expected[[2]int{e.Row, e.Col}] {
+			t.Errorf("want: %v got: %+v", expected, e)
+		}
+	}
+}
+
+func assertSameMatrix(t *testing.T, exp, act *sanctmc.Matrix) {
+	if exp.Size() != act.Size() {
+		t.Fatalf("want: %d rows got: %d", exp.Size(), act.Size())
+	}
+	for i := 0; i < exp.Size(); i++ {
+		for j := 0; j < exp.Size(); j++ {
+			if math.Abs(exp.At(i, j)-act.At(i, j)) > 1e-12 {
+				t.Errorf("want: %v got: %v at (%d, %d)", exp.At(i, j), act.At(i, j), i, j)
+			}
+		}
+	}
+}
+
+func assertSameVector(t *testing.T, exp, act []float64) {
+	for i := range exp {
+		if math.Abs(exp[i]-act[i]) > 1e-12 {
+			t.Errorf("want: %v got: %v", exp, act)
+			return
+		}
+	}
+}
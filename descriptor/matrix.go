@@ -0,0 +1,146 @@
+package sandescriptor
+
+import (
+	"sort"
+
+	ast "github.com/fgrehm/go-san/ast"
+	saneval "github.com/fgrehm/go-san/eval"
+)
+
+// Matrix is a sparse matrix of a single automaton. Its elements are sorted by
+// row and column.
+type Matrix struct {
+	N        int // the number of states of the automaton
+	Elements []*Element
+
+	rows     []int // the elements of row i are found at [rows[i], rows[i+1])
+	identity bool  // identity matrices are skipped by tensor products
+}
+
+// Element is an element of an automaton matrix. Constant elements hold their
+// Value while functional elements depend on the global state and must be
+// evaluated through Eval.
+type Element struct {
+	Row, Col int
+	Value    float64 // the value of constant elements
+
+	terms []term // the functional terms, added up to Value
+}
+
+// term is a product of a coefficient and of functional expressions
+type term struct {
+	coef    float64
+	factors []ast.Expr
+}
+
+// IsFunctional returns true if the element depends on the global state
+func (e *Element) IsFunctional() bool {
+	return len(e.terms) > 0
+}
+
+// Eval evaluates the element on the given global state
+func (e *Element) Eval(scope *saneval.Scope, states saneval.States) (float64, error) {
+	v := e.Value
+	for _, t := range e.terms {
+		prod := t.coef
+		for _, f := range t.factors {
+			x, err := scope.Eval(f, states)
+			if err != nil {
+				return 0, err
+			}
+			prod *= x
+		}
+		v += prod
+	}
+	return v, nil
+}
+
+// Row returns the elements of a row
+func (m *Matrix) Row(i int) []*Element {
+	return m.Elements[m.rows[i]:m.rows[i+1]]
+}
+
+// at returns the element at row i and column j, nil if there is none
+func (m *Matrix) at(i, j int) *Element {
+	row := m.Row(i)
+	k := sort.Search(len(row), func(k int) bool { return row[k].Col >= j })
+	if k < len(row) && row[k].Col == j {
+		return row[k]
+	}
+	return nil
+}
+
+// IsFunctional returns true if any element of the matrix is functional
+func (m *Matrix) IsFunctional() bool {
+	for _, e := range m.Elements {
+		if e.IsFunctional() {
+			return true
+		}
+	}
+	return false
+}
+
+// matrixBuilder accumulates the elements of an automaton matrix
+type matrixBuilder struct {
+	n        int
+	elements map[[2]int]*Element
+}
+
+func newMatrixBuilder(n int) *matrixBuilder {
+	return &matrixBuilder{n: n, elements: map[[2]int]*Element{}}
+}
+
+// add adds coef times the product of factors to an element, factors that are
+// constant must have been folded into coef already
+func (b *matrixBuilder) add(row, col int, coef float64, factors ...ast.Expr) {
+	e, ok := b.elements[[2]int{row, col}]
+	if !ok {
+		e = &Element{Row: row, Col: col}
+		b.elements[[2]int{row, col}] = e
+	}
+	if len(factors) == 0 {
+		e.Value += coef
+		return
+	}
+	e.terms = append(e.terms, term{coef: coef, factors: factors})
+}
+
+// addTerms adds the terms of another element scaled by coef
+func (b *matrixBuilder) addTerms(row, col int, coef float64, from *Element) {
+	b.add(row, col, coef*from.Value)
+	for _, t := range from.terms {
+		b.add(row, col, coef*t.coef, t.factors...)
+	}
+}
+
+func (b *matrixBuilder) build() *Matrix {
+	m := &Matrix{N: b.n, Elements: []*Element{}, rows: make([]int, b.n+1)}
+	for _, e := range b.elements {
+		if e.Value == 0 && !e.IsFunctional() {
+			continue
+		}
+		m.Elements = append(m.Elements, e)
+	}
+	sort.Slice(m.Elements, func(i, j int) bool {
+		a, b := m.Elements[i], m.Elements[j]
+		return a.Row < b.Row || a.Row == b.Row && a.Col < b.Col
+	})
+	for _, e := range m.Elements {
+		m.rows[e.Row+1]++
+	}
+	for i := 0; i < b.n; i++ {
+		m.rows[i+1] += m.rows[i]
+	}
+	return m
+}
+
+// identity returns the identity matrix of order n
+func identity(n int) *Matrix {
+	b := newMatrixBuilder(n)
+	for i := 0; i < n; i++ {
+		b.add(i, i, 1)
+	}
+	m := b.build()
+	m.identity = true
+	return m
+}
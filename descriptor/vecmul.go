@@ -0,0 +1,185 @@
+package sandescriptor
+
+// Size returns the order of the descriptor, which is the number of global
+// states
+func (d *Descriptor) Size() int {
+	return d.Space.Len()
+}
+
+// VecMul computes the row vector by descriptor product y = x * Q without ever
+// building Q. Terms made up of constant matrices are multiplied through the
+// shuffle algorithm, one automaton matrix at a time, while terms that hold
+// functional elements are evaluated on the global state of every nonzero
+// entry of x. VecMul reuses internal buffers and is not safe for concurrent
+// use.
+func (d *Descriptor) VecMul(x, y []float64) error {
+	for j := range y {
+		y[j] = 0
+	}
+
+	for k, m := range d.Local {
+		if m.IsFunctional() {
+			if err := d.functionalFactor(k, m, x, y); err != nil {
+				return err
+			}
+			continue
+		}
+		d.shuffleFactor(k, m, x, y)
+	}
+
+	for _, sync := range d.Syncs {
+		for _, product := range [][]*Matrix{sync.Positive, sync.Negative} {
+			if isFunctional(product) {
+				if err := d.functionalProduct(product, x, y); err != nil {
+					return err
+				}
+				continue
+			}
+			d.shuffleProduct(product, x, y)
+		}
+	}
+	return nil
+}
+
+// Diagonal returns the diagonal elements of the descriptor, functional
+// elements being evaluated on each global state
+func (d *Descriptor) Diagonal() ([]float64, error) {
+	diag := make([]float64, d.Space.Len())
+	local := make([]int, len(d.Space.Automata))
+	states := d.Space.Global(local)
+
+	for s := range diag {
+		d.Space.Decode(s, local)
+		for k, m := range d.Local {
+			e := m.at(local[k], local[k])
+			if e == nil {
+				continue
+			}
+			v, err := e.Eval(d.scope, states)
+			if err != nil {
+				return nil, err
+			}
+			diag[s] += v
+		}
+
+		for _, sync := range d.Syncs {
+			for _, product := range [][]*Matrix{sync.Positive, sync.Negative} {
+				prod := 1.0
+				for k, m := range product {
+					e := m.at(local[k], local[k])
+					if e == nil {
+						prod = 0
+						break
+					}
+					v, err := e.Eval(d.scope, states)
+					if err != nil {
+						return nil, err
+					}
+					prod *= v
+				}
+				diag[s] += prod
+			}
+		}
+	}
+	return diag, nil
+}
+
+// shuffleFactor accumulates on y the product of x by the constant matrix m of
+// the k-th automaton, normal factor I ⊗ m ⊗ I, visiting each block of
+// consecutive states of the automata on the left of k
+func (d *Descriptor) shuffleFactor(k int, m *Matrix, x, y []float64) {
+	right := d.strides[k]
+	block := m.N * right
+	for base := 0; base < len(x); base += block {
+		for _, e := range m.Elements {
+			from := x[base+e.Row*right : base+(e.Row+1)*right]
+			to := y[base+e.Col*right : base+(e.Col+1)*right]
+			for r, v := range from {
+				to[r] += v * e.Value
+			}
+		}
+	}
+}
+
+// shuffleProduct accumulates on y the product of x by a tensor product of
+// constant matrices, multiplying x by one normal factor at a time
+func (d *Descriptor) shuffleProduct(product []*Matrix, x, y []float64) {
+	for _, m := range product {
+		if len(m.Elements) == 0 {
+			return
+		}
+	}
+
+	z, next := x, 0
+	for k, m := range product {
+		if m.identity {
+			continue
+		}
+		if len(d.work[next]) != len(x) {
+			d.work[next] = make([]float64, len(x))
+		}
+		w := d.work[next]
+		for j := range w {
+			w[j] = 0
+		}
+		d.shuffleFactor(k, m, z, w)
+		z, next = w, 1-next
+	}
+
+	for j, v := range z {
+		y[j] += v
+	}
+}
+
+// functionalFactor accumulates on y the product of x by the normal factor of
+// the functional matrix m of the k-th automaton
+func (d *Descriptor) functionalFactor(k int, m *Matrix, x, y []float64) error {
+	local := make([]int, len(d.Space.Automata))
+	states := d.Space.Global(local)
+	for s, v := range x {
+		if v == 0 {
+			continue
+		}
+		d.Space.Decode(s, local)
+		for _, e := range m.Row(local[k]) {
+			val, err := e.Eval(d.scope, states)
+			if err != nil {
+				return err
+			}
+			y[s+(e.Col-local[k])*d.strides[k]] += v * val
+		}
+	}
+	return nil
+}
+
+// functionalProduct accumulates on y the product of x by a tensor product
+// that holds functional elements, which are evaluated on the global state of
+// each row
+func (d *Descriptor) functionalProduct(product []*Matrix, x, y []float64) error {
+	local := make([]int, len(d.Space.Automata))
+	col := make([]int, len(local))
+	states := d.Space.Global(local)
+	emit := func(j int, v float64) {
+		y[j] += v
+	}
+	for s, v := range x {
+		if v == 0 {
+			continue
+		}
+		d.Space.Decode(s, local)
+		copy(col, local)
+		if err := d.productTerms(product, local, states, 0, col, v, emit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isFunctional(product []*Matrix) bool {
+	for _, m := range product {
+		if m.IsFunctional() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,339 @@
+package saneval
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	ast "github.com/fgrehm/go-san/ast"
+	model "github.com/fgrehm/go-san/model"
+	token "github.com/fgrehm/go-san/token"
+)
+
+// States provides the automata states referenced by an expression through the
+// `st Automaton` construct and the nb and lst built-ins
+type States interface {
+	// State returns the index of the current state of an automaton
+	State(automaton string) (int, bool)
+	// StateIndex returns the index of a state of an automaton
+	StateIndex(automaton, state string) (int, bool)
+	// Automata returns the automaton with the given name or the replicas of
+	// the replicated automaton with the given name, in order
+	Automata(name string) []string
+}
+
+// Error is an evaluation error that contains the position of the offending
+// expression
+type Error struct {
+	Pos token.Pos
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("At %s: %s", e.Pos, e.Msg)
+}
+
+// errFunctional is returned when an expression that depends on automata
+// states is evaluated without states
+var errFunctional = errors.New("expression depends on automata states")
+
+// evaluator evaluates expression trees, boolean operators evaluate to 1 when
+// true and to 0 when false
+type evaluator struct {
+	states States
+	ident  func(tok token.Token) (float64, error)
+}
+
+func (ev *evaluator) eval(e ast.Expr) (float64, error) {
+	switch e := e.(type) {
+	case *ast.BasicLit:
+		return literal(e.Token)
+	case *ast.Ident:
+		if e.Index != nil {
+			return 0, errorf(e.Pos(), "Indexed state %s can only be compared with an automaton state", e)
+		}
+		return ev.ident(e.Token)
+	case *ast.ParenExpr:
+		return ev.eval(e.X)
+	case *ast.StateExpr:
+		return ev.state(e)
+	case *ast.UnaryExpr:
+		return ev.unary(e)
+	case *ast.BinaryExpr:
+		return ev.binary(e)
+	case *ast.CondExpr:
+		cond, err := ev.eval(e.Cond)
+		if err != nil {
+			return 0, err
+		}
+		if cond != 0 {
+			return ev.eval(e.X)
+		}
+		return ev.eval(e.Y)
+	case *ast.CallExpr:
+		return ev.call(e)
+	case *ast.AggregateExpr:
+		return ev.aggregate(e)
+	case nil:
+		return 0, errorf(token.Pos{}, "Missing expression")
+	}
+	return 0, errorf(e.Pos(), "Unsupported expression %s", e)
+}
+
+func (ev *evaluator) state(e *ast.StateExpr) (float64, error) {
+	if ev.states == nil {
+		return 0, errFunctional
+	}
+	name, err := ev.automaton(e)
+	if err != nil {
+		return 0, err
+	}
+	idx, ok := ev.states.State(name)
+	if !ok {
+		return 0, errorf(e.Automaton.Pos, "Unknown automaton %q", name)
+	}
+	return float64(idx), nil
+}
+
+// automaton returns the name of the automaton referenced by a StateExpr,
+// evaluating the replica index if present
+func (ev *evaluator) automaton(e *ast.StateExpr) (string, error) {
+	return ev.indexedName(e.Automaton, e.Index)
+}
+
+// indexedName returns the name of a replicated automaton or of an indexed
+// state, evaluating its index if present
+func (ev *evaluator) indexedName(name token.Token, index ast.Expr) (string, error) {
+	if index == nil {
+		return name.Text, nil
+	}
+	idx, err := ev.eval(index)
+	if err != nil {
+		return "", err
+	}
+	if idx != math.Trunc(idx) || idx < 0 {
+		return "", errorf(index.Pos(), "Invalid index %v for %q", idx, name.Text)
+	}
+	return model.ReplicaName(name.Text, int(idx)), nil
+}
+
+func (ev *evaluator) unary(e *ast.UnaryExpr) (float64, error) {
+	x, err := ev.eval(e.X)
+	if err != nil {
+		return 0, err
+	}
+	switch e.Op.Type {
+	case token.NEG:
+		return boolean(x == 0), nil
+	case token.SUB:
+		return -x, nil
+	}
+	return 0, errorf(e.Op.Pos, "Unsupported unary operator %q", e.Op.Text)
+}
+
+func (ev *evaluator) binary(e *ast.BinaryExpr) (float64, error) {
+	if e.Op.Type == token.EQUAL || e.Op.Type == token.NEQUAL {
+		if eq, ok, err := ev.stateComparison(e); ok {
+			if err != nil {
+				return 0, err
+			}
+			return boolean(eq == (e.Op.Type == token.EQUAL)), nil
+		}
+	}
+
+	x, err := ev.eval(e.X)
+	if err != nil {
+		return 0, err
+	}
+	y, err := ev.eval(e.Y)
+	if err != nil {
+		return 0, err
+	}
+
+	switch e.Op.Type {
+	case token.SUM:
+		return x + y, nil
+	case token.SUB:
+		return x - y, nil
+	case token.MULT:
+		return x * y, nil
+	case token.DIV:
+		if y == 0 {
+			return 0, errorf(e.Op.Pos, "Division by zero in %s", e)
+		}
+		return x / y, nil
+	case token.MOD:
+		if y == 0 {
+			return 0, errorf(e.Op.Pos, "Division by zero in %s", e)
+		}
+		return math.Mod(x, y), nil
+	case token.AND:
+		return boolean(x != 0 && y != 0), nil
+	case token.OR:
+		return boolean(x != 0 || y != 0), nil
+	case token.EQUAL:
+		return boolean(x == y), nil
+	case token.NEQUAL:
+		return boolean(x != y), nil
+	case token.LT:
+		return boolean(x < y), nil
+	case token.GT:
+		return boolean(x > y), nil
+	case token.LEQ:
+		return boolean(x <= y), nil
+	case token.GEQ:
+		return boolean(x >= y), nil
+	}
+	return 0, errorf(e.Op.Pos, "Unsupported binary operator %q", e.Op.Text)
+}
+
+func (ev *evaluator) call(e *ast.CallExpr) (float64, error) {
+	var result float64
+	for i, arg := range e.Args {
+		v, err := ev.eval(arg)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case i == 0:
+			result = v
+		case e.Fun.Type == token.MIN:
+			result = math.Min(result, v)
+		case e.Fun.Type == token.MAX:
+			result = math.Max(result, v)
+		default:
+			return 0, errorf(e.Fun.Pos, "Unsupported function %q", e.Fun.Text)
+		}
+	}
+	return result, nil
+}
+
+// aggregate evaluates the nb and lst built-ins, lst evaluates to -1 if no
+// automaton is found on the given state
+func (ev *evaluator) aggregate(e *ast.AggregateExpr) (float64, error) {
+	if ev.states == nil {
+		return 0, errFunctional
+	}
+	automata := ev.states.Automata(e.Automata.Text)
+	if len(automata) == 0 {
+		return 0, errorf(e.Automata.Pos, "Unknown automaton %q", e.Automata.Text)
+	}
+	stateName, err := ev.indexedName(e.State.Token, e.State.Index)
+	if err != nil {
+		return 0, err
+	}
+
+	count, last := 0, -1
+	for i, name := range automata {
+		want, ok := ev.states.StateIndex(name, stateName)
+		if !ok {
+			return 0, errorf(e.State.Pos(), "Unknown state %q for automaton %q", stateName, name)
+		}
+		if current, _ := ev.states.State(name); current == want {
+			count++
+			last = i
+		}
+	}
+
+	switch e.Op.Type {
+	case token.NB:
+		return float64(count), nil
+	case token.LST:
+		return float64(last), nil
+	}
+	return 0, errorf(e.Op.Pos, "Unsupported built-in %q", e.Op.Text)
+}
+
+// stateComparison handles the `st Automaton == state` construct, where the
+// state name is looked up on the automaton. It reports false on its second
+// return value if e does not compare an automaton state with a state name.
+func (ev *evaluator) stateComparison(e *ast.BinaryExpr) (bool, bool, error) {
+	st, state := StateComparison(e)
+	if st == nil {
+		return false, false, nil
+	}
+	if ev.states == nil {
+		return false, true, errFunctional
+	}
+
+	name, err := ev.automaton(st)
+	if err != nil {
+		return false, true, err
+	}
+	stateName, err := ev.indexedName(state.Token, state.Index)
+	if err != nil {
+		return false, true, err
+	}
+	want, ok := ev.states.StateIndex(name, stateName)
+	if !ok {
+		if state.Index != nil {
+			return false, true, errorf(state.Pos(), "Unknown state %q for automaton %q", stateName, name)
+		}
+		return false, false, nil
+	}
+	current, err := ev.state(st)
+	if err != nil {
+		return false, true, err
+	}
+	return int(current) == want, true, nil
+}
+
+// StateComparison returns both sides of a comparison between the state of an
+// automaton and a name, as in `st Automaton == state`. Nil is returned if e is
+// not such comparison.
+func StateComparison(e *ast.BinaryExpr) (*ast.StateExpr, *ast.Ident) {
+	if e.Op.Type != token.EQUAL && e.Op.Type != token.NEQUAL {
+		return nil, nil
+	}
+	if st, ok := unparen(e.X).(*ast.StateExpr); ok {
+		if ident, ok := unparen(e.Y).(*ast.Ident); ok {
+			return st, ident
+		}
+	}
+	if st, ok := unparen(e.Y).(*ast.StateExpr); ok {
+		if ident, ok := unparen(e.X).(*ast.Ident); ok {
+			return st, ident
+		}
+	}
+	return nil, nil
+}
+
+func unparen(e ast.Expr) ast.Expr {
+	for {
+		p, ok := e.(*ast.ParenExpr)
+		if !ok {
+			return e
+		}
+		e = p.X
+	}
+}
+
+func literal(tok token.Token) (float64, error) {
+	switch tok.Type {
+	case token.NUMBER:
+		v, err := strconv.ParseInt(tok.Text, 0, 64)
+		if err != nil {
+			return 0, errorf(tok.Pos, "Invalid number %q", tok.Text)
+		}
+		return float64(v), nil
+	case token.FLOAT:
+		v, err := strconv.ParseFloat(tok.Text, 64)
+		if err != nil {
+			return 0, errorf(tok.Pos, "Invalid number %q", tok.Text)
+		}
+		return v, nil
+	}
+	return 0, errorf(tok.Pos, "Invalid literal %q", tok.Text)
+}
+
+func boolean(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func errorf(pos token.Pos, format string, args ...interface{}) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
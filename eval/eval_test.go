@@ -0,0 +1,245 @@
+package saneval_test
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	san "github.com/fgrehm/go-san"
+	ast "github.com/fgrehm/go-san/ast"
+	saneval "github.com/fgrehm/go-san/eval"
+	model "github.com/fgrehm/go-san/model"
+)
+
+func TestEvaluate(t *testing.T) {
+	m := parse(t, `identifiers
+  total = lambda + mu;
+  lambda = 2;
+  mu = 1.5 * half;
+  half = 1 / 2;
+  prio = !(lambda == mu) && 1;
+  neg = -3 * 2;
+  diff = lambda - 1 -1;
+  either = 0 || lambda < mu;
+  cmp = (lambda >= 2) + (mu <= 0.5) + (lambda > mu);
+  rest = 7 % 3;
+  cond = lambda > mu ? lambda : mu;
+  lo = min(lambda, mu, 3);
+  hi = max(lambda, mu, 3);
+  F1 = (st Client == Working) * lambda;
+  F2 = F1 + 1;`)
+
+	scope, err := saneval.Evaluate(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := saneval.Values{
+		"total":  2.75,
+		"lambda": 2,
+		"mu":     0.75,
+		"half":   0.5,
+		"prio":   1,
+		"neg":    -6,
+		"diff":   0,
+		"either": 0,
+		"cmp":    2,
+		"rest":   1,
+		"cond":   2,
+		"lo":     0.75,
+		"hi":     3,
+	}
+	if len(scope.Values) != len(expected) {
+		t.Errorf("want: %v got: %v", expected, scope.Values)
+	}
+	for name, val := range expected {
+		if scope.Values[name] != val {
+			t.Errorf("want: %v got: %v for %s", val, scope.Values[name], name)
+		}
+	}
+
+	for _, name := range []string{"F1", "F2"} {
+		if !scope.IsFunctional(name) {
+			t.Errorf("Expected %s to be functional", name)
+		}
+	}
+}
+
+func TestEvaluate_Error(t *testing.T) {
+	var testData = []struct {
+		src string
+		msg string
+	}{
+		{"identifiers a = b + 1; b = c; c = a;", "At 1:35: Identifier cycle detected: a -> b -> c -> a"},
+		{"identifiers a = a;", "At 1:17: Identifier cycle detected: a -> a"},
+		{"identifiers a = 1;\n b = a * foo;", "At 2:10: Undefined identifier \"foo\""},
+		{"identifiers a = (st A == s) * foo;", "At 1:31: Undefined identifier \"foo\""},
+		{"identifiers a = 1 / (2 * 0);", "At 1:19: Division by zero in 1 / (2 * 0)"},
+		{"identifiers a = 1 % 0;", "At 1:19: Division by zero in 1 % 0"},
+		{"identifiers a = nb [A] s1 + foo;", "At 1:29: Undefined identifier \"foo\""},
+	}
+
+	for _, d := range testData {
+		_, err := saneval.Evaluate(parse(t, d.src))
+		if err == nil {
+			t.Errorf("Expected to error with %q but did not", d.src)
+			continue
+		}
+		if err.Error() != d.msg {
+			t.Errorf("want: %q got: %q", d.msg, err.Error())
+		}
+	}
+}
+
+type testStates map[string]string
+
+func (s testStates) State(automaton string) (int, bool) {
+	st, ok := s[automaton]
+	if !ok {
+		return 0, false
+	}
+	return int(st[len(st)-1] - '0'), true
+}
+
+func (s testStates) Automata(name string) []string {
+	automata := []string{}
+	for automaton := range s {
+		if automaton == name || strings.HasPrefix(automaton, name+"[") {
+			automata = append(automata, automaton)
+		}
+	}
+	sort.Strings(automata)
+	return automata
+}
+
+func (s testStates) StateIndex(automaton, state string) (int, bool) {
+	if !strings.HasPrefix(state, "s") {
+		return 0, false
+	}
+	return int(state[len(state)-1] - '0'), true
+}
+
+func TestScopeEval(t *testing.T) {
+	m := parse(t, `identifiers
+  lambda = 2;
+  F1 = (st A == s1) * lambda;
+  F2 = F1 + st B;
+  busy = nb [P] s1;
+  last = lst [P] s1;`)
+	scope, err := saneval.Evaluate(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testData = []struct {
+		states   testStates
+		ident    string
+		expected float64
+	}{
+		{testStates{"A": "s1", "B": "s0"}, "F1", 2},
+		{testStates{"A": "s0", "B": "s0"}, "F1", 0},
+		{testStates{"A": "s1", "B": "s3"}, "F2", 5},
+		{testStates{"A": "s2", "B": "s3"}, "F2", 3},
+		{testStates{"P[0]": "s1", "P[1]": "s0", "P[2]": "s1"}, "busy", 2},
+		{testStates{"P[0]": "s1", "P[1]": "s0", "P[2]": "s1"}, "last", 2},
+		{testStates{"P[0]": "s0", "P[1]": "s0"}, "busy", 0},
+		{testStates{"P[0]": "s0", "P[1]": "s0"}, "last", -1},
+		{testStates{"P": "s1"}, "busy", 1},
+	}
+
+	for _, d := range testData {
+		v, err := scope.Eval(identExpr(m, d.ident), d.states)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if v != d.expected {
+			t.Errorf("want: %v got: %v for %s with %v", d.expected, v, d.ident, d.states)
+		}
+	}
+
+	if _, err := scope.Eval(identExpr(m, "F1"), nil); err == nil {
+		t.Error("Expected to error when evaluating a functional identifier without states")
+	}
+
+	// bound values take precedence and functional identifiers are kept
+	bound := scope.Bind(saneval.Values{"lambda": 3})
+	if v, err := bound.Eval(identExpr(m, "F1"), testStates{"A": "s1"}); err != nil || v != 3 {
+		t.Errorf("want: 3 got: %v (%v)", v, err)
+	}
+	if v, err := scope.Eval(identExpr(m, "F1"), testStates{"A": "s1"}); err != nil || v != 2 {
+		t.Errorf("Expected binding not to change the original scope, got %v (%v)", v, err)
+	}
+}
+
+func TestScopeConstant(t *testing.T) {
+	m := parse(t, `identifiers
+  lambda = 2;
+  F1 = (st A == s1) * lambda;
+  rate = lambda * 3;
+  rate2 = F1 * 2;
+  rate3 = nb [P] s1;
+  rate4 = min(lambda, 1) + 0.5;`)
+	scope, err := saneval.Evaluate(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testData = []struct {
+		ident    string
+		value    float64
+		constant bool
+	}{
+		{"rate", 6, true},
+		{"rate2", 0, false},
+		{"rate3", 0, false},
+		{"rate4", 1.5, true},
+	}
+	for _, d := range testData {
+		v, constant, err := scope.Constant(identExpr(m, d.ident), "")
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if v != d.value || constant != d.constant {
+			t.Errorf("want: %v, %v got: %v, %v for %s", d.value, d.constant, v, constant, d.ident)
+		}
+	}
+
+	for text, exp := range map[string]float64{"lambda": 2, "0.5": 0.5} {
+		if v, constant, err := scope.Constant(nil, text); err != nil || !constant || v != exp {
+			t.Errorf("want: %v got: %v, %v, %v for %q", exp, v, constant, err, text)
+		}
+	}
+
+	bad := parse(t, `identifiers
+  a = foo * 2;
+  b = (st A == s1) * foo;
+  c = 1 / 0;`)
+	for _, ident := range bad.Identifiers {
+		if _, _, err := scope.Constant(ident.Expr, ""); err == nil {
+			t.Errorf("Expected %s to error", ident.Expr)
+		}
+	}
+	if _, _, err := scope.Constant(nil, "foo"); err == nil || err.Error() != `Unable to evaluate "foo"` {
+		t.Errorf("Expected to error on an unknown identifier, got %v", err)
+	}
+}
+
+func parse(t *testing.T, src string) *model.Model {
+	m, err := san.Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// identExpr returns the expression of an identifier
+func identExpr(m *model.Model, name string) ast.Expr {
+	for _, ident := range m.Identifiers {
+		if ident.Name == name {
+			return ident.Expr
+		}
+	}
+	return nil
+}
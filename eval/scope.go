@@ -0,0 +1,312 @@
+package saneval
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	ast "github.com/fgrehm/go-san/ast"
+	model "github.com/fgrehm/go-san/model"
+	token "github.com/fgrehm/go-san/token"
+)
+
+// Values maps identifiers names to their evaluated values
+type Values map[string]float64
+
+// Scope holds the identifiers of a model. Identifiers that do not depend on
+// automata states are evaluated once and kept on Values while functional
+// identifiers are evaluated against the states provided to Eval.
+type Scope struct {
+	Values Values
+	funcs  map[string]ast.Expr
+}
+
+// Evaluate evaluates the identifiers of a model, resolving references to
+// other identifiers regardless of the order they have been defined. Cycles and
+// references to undefined identifiers are reported as errors.
+func Evaluate(m *model.Model) (*Scope, error) {
+	r := &resolver{
+		scope: &Scope{
+			Values: Values{},
+			funcs:  map[string]ast.Expr{},
+		},
+		idents:   map[string]*model.Identifier{},
+		states:   networkStates(m.Network),
+		visiting: map[string]bool{},
+	}
+	r.ev = &evaluator{ident: r.resolveToken}
+	for _, ident := range m.Identifiers {
+		r.idents[ident.Name] = ident
+	}
+
+	for _, ident := range m.Identifiers {
+		if _, err := r.resolve(ident.Name, token.Pos{}); err != nil && err != errFunctional {
+			return nil, err
+		}
+	}
+	return r.scope, nil
+}
+
+// IsFunctional returns true if the identifier depends on automata states
+func (s *Scope) IsFunctional(name string) bool {
+	_, ok := s.funcs[name]
+	return ok
+}
+
+// Bind returns a scope that extends s with the given values, which take
+// precedence over the identifiers of s with the same names
+func (s *Scope) Bind(values Values) *Scope {
+	bound := &Scope{Values: Values{}, funcs: map[string]ast.Expr{}}
+	for name, v := range s.Values {
+		bound.Values[name] = v
+	}
+	for name, e := range s.funcs {
+		if _, ok := values[name]; !ok {
+			bound.funcs[name] = e
+		}
+	}
+	for name, v := range values {
+		bound.Values[name] = v
+	}
+	return bound
+}
+
+// Eval evaluates an expression using the scope identifiers, functional
+// identifiers and `st` constructs are evaluated against the provided states.
+// Boolean operators evaluate to 1 when true and to 0 when false.
+func (s *Scope) Eval(e ast.Expr, states States) (float64, error) {
+	ev := &evaluator{states: states}
+	ev.ident = func(tok token.Token) (float64, error) {
+		if v, ok := s.Values[tok.Text]; ok {
+			return v, nil
+		}
+		if expr, ok := s.funcs[tok.Text]; ok {
+			return ev.eval(expr)
+		}
+		return 0, errorf(tok.Pos, "Undefined identifier %q", tok.Text)
+	}
+
+	v, err := ev.eval(e)
+	if err == errFunctional {
+		return 0, errorf(e.Pos(), "%s depends on automata states", e)
+	}
+	return v, err
+}
+
+// Constant evaluates an expression that does not depend on automata states,
+// false is returned for the ones that do, which are left for Eval to evaluate
+// against states. Models that have not been parsed might carry the textual
+// representation of an expression only, given as text when e is nil, in which
+// case it must be either a number or an identifier.
+func (s *Scope) Constant(e ast.Expr, text string) (float64, bool, error) {
+	if e == nil {
+		if v, ok := s.Values[text]; ok {
+			return v, true, nil
+		}
+		v, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("Unable to evaluate %q", text)
+		}
+		return v, true, nil
+	}
+
+	functional, err := s.functional(e)
+	if err != nil || functional {
+		return 0, false, err
+	}
+	v, err := s.Eval(e, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	return v, true, nil
+}
+
+// functional returns true if an expression refers to automata states, either
+// directly or through functional identifiers. References to undefined
+// identifiers are reported as errors.
+func (s *Scope) functional(e ast.Expr) (bool, error) {
+	functional := false
+	var err error
+	ast.Inspect(e, func(n ast.Expr) bool {
+		if err != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.StateExpr, *ast.AggregateExpr:
+			functional = true
+		case *ast.BinaryExpr:
+			// the state compared against is a name rather than an identifier
+			if st, _ := StateComparison(n); st != nil {
+				functional = true
+				return false
+			}
+		case *ast.Ident:
+			if _, ok := s.Values[n.Token.Text]; ok {
+				break
+			}
+			if s.IsFunctional(n.Token.Text) {
+				functional = true
+				break
+			}
+			err = errorf(n.Token.Pos, "Undefined identifier %q", n.Token.Text)
+		}
+		return true
+	})
+	return functional, err
+}
+
+// AutomatonName returns the name of the automaton referenced by a StateExpr,
+// evaluating its replica index if present
+func (s *Scope) AutomatonName(e *ast.StateExpr) (string, error) {
+	return s.indexedName(e.Automaton, e.Index)
+}
+
+// StateName returns the name of the state referenced by an Ident, evaluating
+// its index if present
+func (s *Scope) StateName(e *ast.Ident) (string, error) {
+	return s.indexedName(e.Token, e.Index)
+}
+
+func (s *Scope) indexedName(name token.Token, index ast.Expr) (string, error) {
+	ev := &evaluator{}
+	ev.ident = func(tok token.Token) (float64, error) {
+		if v, ok := s.Values[tok.Text]; ok {
+			return v, nil
+		}
+		return 0, errorf(tok.Pos, "Undefined identifier %q", tok.Text)
+	}
+	n, err := ev.indexedName(name, index)
+	if err == errFunctional {
+		return "", errorf(index.Pos(), "%s depends on automata states", index)
+	}
+	return n, err
+}
+
+// resolver evaluates identifiers on demand, keeping track of the ones being
+// evaluated in order to detect cycles
+type resolver struct {
+	ev       *evaluator
+	scope    *Scope
+	idents   map[string]*model.Identifier
+	states   map[string]map[string]bool
+	visiting map[string]bool
+	path     []string
+}
+
+func (r *resolver) resolveToken(tok token.Token) (float64, error) {
+	return r.resolve(tok.Text, tok.Pos)
+}
+
+// resolve returns the value of an identifier, errFunctional is returned for
+// identifiers that depend on automata states
+func (r *resolver) resolve(name string, pos token.Pos) (float64, error) {
+	if v, ok := r.scope.Values[name]; ok {
+		return v, nil
+	}
+	if r.scope.IsFunctional(name) {
+		return 0, errFunctional
+	}
+	ident, ok := r.idents[name]
+	if !ok {
+		return 0, errorf(pos, "Undefined identifier %q", name)
+	}
+	if r.visiting[name] {
+		cycle := append(r.path[indexOf(r.path, name):], name)
+		return 0, errorf(pos, "Identifier cycle detected: %s", strings.Join(cycle, " -> "))
+	}
+
+	r.visiting[name] = true
+	r.path = append(r.path, name)
+	v, err := r.value(ident)
+	r.path = r.path[:len(r.path)-1]
+	delete(r.visiting, name)
+
+	switch err {
+	case nil:
+		r.scope.Values[name] = v
+	case errFunctional:
+		r.scope.funcs[name] = ident.Expr
+	}
+	return v, err
+}
+
+func (r *resolver) value(ident *model.Identifier) (float64, error) {
+	if ident.Expr == nil {
+		// models that have not been parsed (like the ones built by hand) might
+		// only carry the constant value around
+		switch v := ident.Value.(type) {
+		case int:
+			return float64(v), nil
+		case int64:
+			return float64(v), nil
+		case float32:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		}
+		return 0, fmt.Errorf("Unable to evaluate identifier %q: no expression available", ident.Name)
+	}
+
+	if err := r.checkReferences(ident.Expr); err != nil {
+		return 0, err
+	}
+	return r.ev.eval(ident.Expr)
+}
+
+// checkReferences resolves every identifier referenced by an expression so
+// that undefined names and cycles get reported even on functional expressions
+func (r *resolver) checkReferences(e ast.Expr) error {
+	var err error
+	ast.Inspect(e, func(n ast.Expr) bool {
+		if err != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.BinaryExpr:
+			if st, state := StateComparison(n); st != nil && (state.Index != nil || r.isState(st.Automaton.Text, state.Token.Text)) {
+				return false
+			}
+		case *ast.Ident:
+			if _, resolveErr := r.resolve(n.Token.Text, n.Token.Pos); resolveErr != errFunctional {
+				err = resolveErr
+			}
+		}
+		return true
+	})
+	return err
+}
+
+// isState returns true if name is a state of the automaton. Names compared
+// against unknown automata are assumed to be states as well.
+func (r *resolver) isState(automaton, name string) bool {
+	states, ok := r.states[automaton]
+	return !ok || states[name]
+}
+
+// networkStates returns the names of the states of each automaton
+func networkStates(n *model.Network) map[string]map[string]bool {
+	states := map[string]map[string]bool{}
+	if n == nil {
+		return states
+	}
+	for _, aut := range n.Automata {
+		names := map[string]bool{}
+		for _, state := range aut.StateNames() {
+			names[state] = true
+		}
+		states[aut.Name] = names
+		if aut.Group != "" {
+			states[aut.Group] = names
+		}
+	}
+	return states
+}
+
+func indexOf(list []string, s string) int {
+	for i, item := range list {
+		if item == s {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,144 @@
+package san
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	ast "github.com/fgrehm/go-san/ast"
+	model "github.com/fgrehm/go-san/model"
+	parser "github.com/fgrehm/go-san/parser"
+	token "github.com/fgrehm/go-san/token"
+)
+
+// ParseFiles parses a textual san model split across the files stored at
+// paths. The blocks of the files are merged in order, so that identifiers,
+// domains, events, automata and results can be defined on any of them. Files
+// that define automata must agree on the name and type of the network, and
+// the reachability function must be defined by a single file. Positions refer
+// to the file each definition comes from and definitions repeated across files
+// are reported as errors, while errors that cannot be traced back to a single
+// file refer to all of them.
+func ParseFiles(paths ...string) (*model.Model, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("No files to parse")
+	}
+
+	var errs parser.ErrorList
+	files := []*ast.File{}
+	for _, path := range paths {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		file, err := parser.ParseFile(path, src)
+		if list, ok := err.(parser.ErrorList); ok {
+			errs = append(errs, list...)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	errs.Sort()
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	file, err := mergeFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	return translateFile(strings.Join(paths, ", "), file)
+}
+
+// mergeFiles merges the blocks of several files into a single file
+func mergeFiles(files []*ast.File) (*ast.File, error) {
+	var errs parser.ErrorList
+	defined := map[string]token.Token{}
+	define := func(kind string, name token.Token) bool {
+		key := kind + " " + name.Text
+		if first, ok := defined[key]; ok {
+			errs.Add(name.Pos, fmt.Errorf("%s %q already defined at %s", kind, name.Text, first.Pos))
+			return false
+		}
+		defined[key] = name
+		return true
+	}
+
+	merged := &ast.File{}
+	for _, f := range files {
+		if f.Identifiers != nil {
+			if merged.Identifiers == nil {
+				merged.Identifiers = &ast.IdentifiersDefinition{Token: f.Identifiers.Token, Assignments: []*ast.IdentifierAssignment{}}
+			}
+			for _, a := range f.Identifiers.Assignments {
+				if define("Identifier", a.Identifier) {
+					merged.Identifiers.Assignments = append(merged.Identifiers.Assignments, a)
+				}
+			}
+		}
+
+		if f.Domains != nil {
+			if merged.Domains == nil {
+				merged.Domains = &ast.DomainsDefinition{Token: f.Domains.Token, Definitions: []*ast.DomainDefinition{}}
+			}
+			for _, d := range f.Domains.Definitions {
+				if define("Domain", d.Name) {
+					merged.Domains.Definitions = append(merged.Domains.Definitions, d)
+				}
+			}
+		}
+
+		if f.Events != nil {
+			if merged.Events == nil {
+				merged.Events = &ast.EventsDefinition{Token: f.Events.Token, Descriptions: []*ast.EventDescription{}}
+			}
+			for _, e := range f.Events.Descriptions {
+				if define("Event", e.Name) {
+					merged.Events.Descriptions = append(merged.Events.Descriptions, e)
+				}
+			}
+		}
+
+		if f.Reachability != nil {
+			if merged.Reachability != nil {
+				first := merged.Reachability.Tokens[0]
+				errs.Add(f.Reachability.Tokens[0].Pos, fmt.Errorf("Reachability function already defined at %s", first.Pos))
+			} else {
+				merged.Reachability = f.Reachability
+			}
+		}
+
+		if f.Network != nil {
+			if merged.Network == nil {
+				merged.Network = &ast.NetworkDefinition{Token: f.Network.Token, Name: f.Network.Name, Type: f.Network.Type, Automata: []*ast.AutomatonDescription{}}
+			} else if n := merged.Network; n.Name.Text != f.Network.Name.Text || n.Type.Text != f.Network.Type.Text {
+				errs.Add(f.Network.Token.Pos, fmt.Errorf("Network %s (%s) does not match network %s (%s) defined at %s", f.Network.Name.Text, f.Network.Type.Text, n.Name.Text, n.Type.Text, n.Token.Pos))
+			}
+			for _, a := range f.Network.Automata {
+				if define("Automaton", a.Name) {
+					merged.Network.Automata = append(merged.Network.Automata, a)
+				}
+			}
+		}
+
+		if f.Results != nil {
+			if merged.Results == nil {
+				merged.Results = &ast.ResultsDefinition{Token: f.Results.Token, Descriptions: []*ast.ResultDescription{}}
+			}
+			for _, r := range f.Results.Descriptions {
+				if define("Result", r.Label) {
+					merged.Results.Descriptions = append(merged.Results.Descriptions, r)
+				}
+			}
+		}
+	}
+
+	errs.Sort()
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
@@ -3,11 +3,31 @@ package sanmodel
 import (
 	"bytes"
 	"encoding/gob"
+	"fmt"
+	"strings"
+
+	ast "github.com/fgrehm/go-san/ast"
+	token "github.com/fgrehm/go-san/token"
 )
 
+func init() {
+	// Expression trees are kept behind the ast.Expr interface, gob needs to
+	// know about its implementations in order to Copy models
+	gob.Register(&ast.BasicLit{})
+	gob.Register(&ast.Ident{})
+	gob.Register(&ast.StateExpr{})
+	gob.Register(&ast.UnaryExpr{})
+	gob.Register(&ast.BinaryExpr{})
+	gob.Register(&ast.ParenExpr{})
+	gob.Register(&ast.CondExpr{})
+	gob.Register(&ast.CallExpr{})
+	gob.Register(&ast.AggregateExpr{})
+}
+
 // Model represents a model that has been parsed from a .san file
 type Model struct {
 	Identifiers  Identifiers   `json:"identifiers"`
+	Domains      Domains       `json:"domains"`
 	Events       Events        `json:"events"`
 	Reachability *Reachability `json:"reachability"`
 	Network      *Network      `json:"network"`
@@ -20,16 +40,34 @@ type Identifier struct {
 	Name  string      `json:"name"`
 	Type  string      `json:"type"`
 	Value interface{} `json:"value"`
+	Expr  ast.Expr    `json:"-"` // the expression tree of the value
+	Pos   token.Pos   `json:"-"` // the position of the name, if parsed
 }
 
 // Identifiers represent a collection of identifiers present on the `identifiers` block
 type Identifiers []*Identifier
 
+// Domain represents a named range of indexes that has been parsed from the
+// `domains` block
+type Domain struct {
+	Name string `json:"name"`
+	Low  int    `json:"low"`
+	High int    `json:"high"`
+
+	Pos token.Pos `json:"-"` // the position of the name, if parsed
+}
+
+// Domains represent a collection of domains present on the `domains` block
+type Domains []*Domain
+
 // Event represents a single event that has been parsed from the `events` block
 type Event struct {
 	Name string `json:"name"`
 	Type string `json:"type"`
 	Rate string `json:"rate"`
+
+	RateExpr ast.Expr  `json:"-"` // the expression tree of Rate
+	Pos      token.Pos `json:"-"` // the position of the name, if parsed
 }
 
 // Events represent a collection of events present on the `events` block
@@ -37,21 +75,36 @@ type Events []*Event
 
 // Reachability represents the reachability information about the model network
 type Reachability struct {
-	Partial    bool   `json:"partial"`
-	Expression string `json:"expression"`
+	Partial    bool     `json:"partial"`
+	Expression string   `json:"expression"`
+	Expr       ast.Expr `json:"-"` // the expression tree of Expression
 }
 
-// Network aggregates automata information from the `network` block
+// Network aggregates automata information from the `network` block. Its Type
+// is either "continuous" or "discrete". Discrete networks are interpreted as
+// discrete-time Markov chains, where the rate of an event is the probability
+// of it firing at each time step.
 type Network struct {
 	Name     string   `json:"name"`
 	Type     string   `json:"type"`
 	Automata Automata `json:"automata"`
 }
 
-// Automaton represents a single automaton present on the `network` block
+// IsDiscrete returns true for discrete-time networks
+func (n *Network) IsDiscrete() bool {
+	return n.Type == "discrete"
+}
+
+// Automaton represents a single automaton present on the `network` block.
+// Replicated automata are expanded into one Automaton per replica, each of
+// them named after ReplicaName and sharing the same Group.
 type Automaton struct {
 	Name        string      `json:"name"`
+	Group       string      `json:"group,omitempty"`
+	States      []string    `json:"states"`
 	Transitions Transitions `json:"transitions"`
+
+	Pos token.Pos `json:"-"` // the position of the name, if parsed
 }
 
 // Transitions represent a collection of automaton transitions
@@ -71,6 +124,9 @@ type Transition struct {
 type TransitionEvent struct {
 	EventName   string `json:"name"`
 	Probability string `json:"probability"`
+
+	ProbabilityExpr ast.Expr  `json:"-"` // the expression tree of Probability
+	Pos             token.Pos `json:"-"` // the position of the event name, if parsed
 }
 
 // TransitionEvents represents a collection of transition events
@@ -78,8 +134,9 @@ type TransitionEvents []*TransitionEvent
 
 // Result represents a single result present on the `results` block
 type Result struct {
-	Label      string `json:"label"`
-	Expression string `json:"expression"`
+	Label      string   `json:"label"`
+	Expression string   `json:"expression"`
+	Expr       ast.Expr `json:"-"` // the expression tree of Expression
 }
 
 // Results represents a collection of results present on the `results` block
@@ -89,6 +146,7 @@ type Results []*Result
 func New() *Model {
 	return &Model{
 		Identifiers:  Identifiers{},
+		Domains:      Domains{},
 		Events:       Events{},
 		Reachability: &Reachability{},
 		Network: &Network{
@@ -118,6 +176,21 @@ func (m *Model) AddIdentifier(i *Identifier) {
 	m.Identifiers = append(m.Identifiers, i)
 }
 
+// AddDomain adds a Domain to the model
+func (m *Model) AddDomain(d *Domain) {
+	m.Domains = append(m.Domains, d)
+}
+
+// Domain returns the domain with the given name or nil if it does not exist
+func (m *Model) Domain(name string) *Domain {
+	for _, d := range m.Domains {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
 // AddEvent adds an Event to the model
 func (m *Model) AddEvent(e *Event) {
 	m.Events = append(m.Events, e)
@@ -133,6 +206,61 @@ func (n *Network) AddAutomaton(a *Automaton) {
 	n.Automata = append(n.Automata, a)
 }
 
+// Automaton returns the automaton with the given name or nil if it does not
+// exist
+func (n *Network) Automaton(name string) *Automaton {
+	for _, a := range n.Automata {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// ReplicaName returns the name of a replica of a replicated automaton or of
+// an indexed state
+func ReplicaName(group string, index int) string {
+	return fmt.Sprintf("%s[%d]", group, index)
+}
+
+// ReplicaIndex returns the index of a replica of a replicated automaton, false
+// is returned for automata that are not replicated
+func (a *Automaton) ReplicaIndex() (int, bool) {
+	var index int
+	if a.Group == "" {
+		return 0, false
+	}
+	if _, err := fmt.Sscanf(strings.TrimPrefix(a.Name, a.Group), "[%d]", &index); err != nil {
+		return 0, false
+	}
+	return index, true
+}
+
+// StateNames returns the names of the automaton states. For automata built
+// without a list of states, the states are collected from the transitions in
+// the order they appear.
+func (a *Automaton) StateNames() []string {
+	if len(a.States) > 0 {
+		return a.States
+	}
+	seen := map[string]bool{}
+	states := []string{}
+	for _, t := range a.Transitions {
+		for _, state := range []string{t.From, t.To} {
+			if !seen[state] {
+				seen[state] = true
+				states = append(states, state)
+			}
+		}
+	}
+	return states
+}
+
+// AddState adds a state to the automaton
+func (a *Automaton) AddState(name string) {
+	a.States = append(a.States, name)
+}
+
 // AddTransition adds a Transition to the automaton
 func (a *Automaton) AddTransition(t *Transition) {
 	a.Transitions = append(a.Transitions, t)
@@ -13,6 +13,7 @@ type formatter func(*model.Model, *bytes.Buffer) error
 
 var formatters = []formatter{
 	formatIdentifiers,
+	formatDomains,
 	formatEvents,
 	formatReachability,
 	formatNetwork,
@@ -38,13 +39,29 @@ func formatIdentifiers(m *model.Model, buf *bytes.Buffer) error {
 	return nil
 }
 
+func formatDomains(m *model.Model, buf *bytes.Buffer) error {
+	if len(m.Domains) == 0 {
+		return nil
+	}
+
+	buf.WriteString("domains\n")
+	for _, domain := range m.Domains {
+		buf.WriteString(fmt.Sprintf("  %s = [%d..%d];\n", domain.Name, domain.Low, domain.High))
+	}
+	return nil
+}
+
 func formatEvents(m *model.Model, buf *bytes.Buffer) error {
 	buf.WriteString("events\n")
 	for _, event := range m.Events {
+		rate := event.Rate
+		if event.RateExpr != nil {
+			rate = event.RateExpr.String()
+		}
 		if event.Type == "local" {
-			buf.WriteString(fmt.Sprintf("  loc %s (%s);\n", event.Name, event.Rate))
+			buf.WriteString(fmt.Sprintf("  loc %s (%s);\n", event.Name, rate))
 		} else {
-			buf.WriteString(fmt.Sprintf("  syn %s (%s);\n", event.Name, event.Rate))
+			buf.WriteString(fmt.Sprintf("  syn %s (%s);\n", event.Name, rate))
 		}
 	}
 	return nil
@@ -63,10 +80,29 @@ func formatNetwork(m *model.Model, buf *bytes.Buffer) error {
 	network := m.Network
 
 	buf.WriteString(fmt.Sprintf("network %s (%s)\n", network.Name, network.Type))
-	for _, aut := range network.Automata {
-		buf.WriteString(fmt.Sprintf("  aut %s\n", aut.Name))
+	for i := 0; i < len(network.Automata); i++ {
+		aut := network.Automata[i]
+		if aut.Group == "" {
+			buf.WriteString(fmt.Sprintf("  aut %s\n", aut.Name))
+		} else {
+			// replicas are written back as a single replicated automaton
+			replicas := 1
+			for i+1 < len(network.Automata) && network.Automata[i+1].Group == aut.Group {
+				replicas++
+				i++
+			}
+			if first, ok := aut.ReplicaIndex(); ok && first != 0 {
+				buf.WriteString(fmt.Sprintf("  aut %s[%d..%d]\n", aut.Group, first, first+replicas-1))
+			} else {
+				buf.WriteString(fmt.Sprintf("  aut %s[%d]\n", aut.Group, replicas))
+			}
+		}
 
-		for _, state := range extractStates(aut.Transitions) {
+		states := aut.States
+		if len(states) == 0 {
+			states = extractStates(aut.Transitions)
+		}
+		for _, state := range states {
 			buf.WriteString(fmt.Sprintf("    stt %s\n", state))
 
 			for _, transition := range aut.Transitions {
@@ -74,7 +110,9 @@ func formatNetwork(m *model.Model, buf *bytes.Buffer) error {
 					events := []string{}
 					for _, e := range transition.Events {
 						event := e.EventName
-						if e.Probability != "" {
+						if e.ProbabilityExpr != nil {
+							event += fmt.Sprintf("(%s)", e.ProbabilityExpr)
+						} else if e.Probability != "" {
 							event += fmt.Sprintf("(%s)", e.Probability)
 						}
 						events = append(events, event)
@@ -0,0 +1,73 @@
+package sanparser
+
+import (
+	ast "github.com/fgrehm/go-san/ast"
+	token "github.com/fgrehm/go-san/token"
+)
+
+func parseDomains(f *ast.File, p *parser, domainsToken token.Token) error {
+	defer un(trace(p, "parseDomains"))
+
+	domainsDef := &ast.DomainsDefinition{
+		Token:       domainsToken,
+		Definitions: []*ast.DomainDefinition{},
+	}
+	f.Domains = domainsDef
+	p.comment(&domainsDef.LeadComment, &domainsDef.LineComment, domainsToken, domainsToken)
+
+	for n := 0; ; n++ {
+		tok := p.scan()
+		if tok.Type == token.EOF || tok.Type.IsKeyword() {
+			if n == 0 {
+				return p.unexpected(tok, "a list of domains", token.IDENTIFIER)
+			}
+			p.unscan()
+			break
+		}
+
+		domain, err := parseDomainDefinition(p, tok)
+		if err != nil {
+			p.error(err)
+			p.sync()
+			continue
+		}
+		domainsDef.Definitions = append(domainsDef.Definitions, domain)
+	}
+
+	return nil
+}
+
+func parseDomainDefinition(p *parser, tok token.Token) (*ast.DomainDefinition, error) {
+	defer un(trace(p, "parseDomainDefinition"))
+
+	var err error
+	first := tok
+	if tok.Type != token.IDENTIFIER {
+		return nil, p.unexpected(tok, "", token.IDENTIFIER)
+	}
+	domain := &ast.DomainDefinition{Name: tok}
+
+	tok = p.scan()
+	if tok.Type != token.ASSIGN {
+		return nil, p.unexpected(tok, "", token.ASSIGN)
+	}
+
+	tok = p.scan()
+	if tok.Type != token.LBRACK {
+		return nil, p.unexpected(tok, "", token.LBRACK)
+	}
+	domain.Range, err = parseRange(p, tok)
+	if err != nil {
+		return nil, err
+	}
+	if domain.Range.High == nil {
+		return nil, p.unexpected(domain.Range.Rbrack, "a range of indexes like [0..N]", token.RANGE)
+	}
+
+	tok = p.scan()
+	if tok.Type != token.SEMICOLON {
+		return nil, p.unexpected(tok, "", token.SEMICOLON)
+	}
+	p.comment(&domain.LeadComment, &domain.LineComment, first, tok)
+	return domain, nil
+}
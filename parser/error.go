@@ -2,6 +2,8 @@ package sanparser
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	token "github.com/fgrehm/go-san/token"
 )
@@ -15,3 +17,133 @@ type PosError struct {
 func (e *PosError) Error() string {
 	return fmt.Sprintf("At %s: %s", e.Pos, e.Err)
 }
+
+// UnexpectedError is the cause of the PosErrors raised when the parser finds
+// a token other than the ones it expects. Expected holds the types of the
+// tokens that would have been accepted and Want describes them whenever they
+// make up a construct, like an operand.
+type UnexpectedError struct {
+	Found    token.Token
+	Expected []token.Type
+	Want     string
+}
+
+func (e *UnexpectedError) Error() string {
+	want := e.Want
+	if want == "" {
+		wants := []string{}
+		for _, t := range e.Expected {
+			wants = append(wants, describe(t))
+		}
+		want = strings.Join(wants, ", ")
+		if n := len(wants); n > 1 {
+			want = strings.Join(wants[:n-1], ", ") + " or " + wants[n-1]
+		}
+	}
+	if e.Found.Type == token.EOF {
+		return fmt.Sprintf("Unexpected end of file. Expected %s", want)
+	}
+	return fmt.Sprintf("Unexpected token found: %q. Expected %s", e.Found.Text, want)
+}
+
+var punctuation = map[token.Type]string{
+	token.SEMICOLON: "a ;",
+	token.LPAREN:    "a (",
+	token.RPAREN:    "a )",
+	token.LBRACK:    "a [",
+	token.RBRACK:    "a ]",
+	token.RANGE:     "a ..",
+	token.ASSIGN:    "an =",
+	token.COLON:     "a :",
+	token.COMMA:     "a ,",
+}
+
+// describe returns how tokens of a type are referred to on error messages
+func describe(t token.Type) string {
+	switch {
+	case t == token.IDENTIFIER:
+		return "an identifier"
+	case t == token.NUMBER:
+		return "a number"
+	case t == token.EOF:
+		return "the end of file"
+	case t.IsKeyword() || t.IsBuiltin():
+		return fmt.Sprintf("the '%s' keyword", strings.ToLower(t.String()))
+	}
+	if s, ok := punctuation[t]; ok {
+		return s
+	}
+	return t.String()
+}
+
+// ErrorList is a list of *PosErrors, like go/scanner.ErrorList. The zero
+// value is an empty list ready to use.
+type ErrorList []*PosError
+
+// Add adds a PosError with the given position and error to the list.
+func (l *ErrorList) Add(pos token.Pos, err error) {
+	*l = append(*l, &PosError{Pos: pos, Err: err})
+}
+
+// Reset resets the list to no errors.
+func (l *ErrorList) Reset() { *l = (*l)[0:0] }
+
+// ErrorList implements the sort interface.
+func (l ErrorList) Len() int      { return len(l) }
+func (l ErrorList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+
+func (l ErrorList) Less(i, j int) bool {
+	e, f := l[i].Pos, l[j].Pos
+	if e.Filename != f.Filename {
+		return e.Filename < f.Filename
+	}
+	if e.Line != f.Line {
+		return e.Line < f.Line
+	}
+	if e.Column != f.Column {
+		return e.Column < f.Column
+	}
+	return l[i].Err.Error() < l[j].Err.Error()
+}
+
+// Sort sorts the list by filename and position, errors at the same position being sorted
+// by message.
+func (l ErrorList) Sort() {
+	sort.Sort(l)
+}
+
+// RemoveMultiples sorts the list and removes all but the first error per
+// line of each file.
+func (l *ErrorList) RemoveMultiples() {
+	sort.Sort(l)
+	var last token.Pos // initial last.Line is != any legal error line
+	i := 0
+	for _, e := range *l {
+		if e.Pos.Filename != last.Filename || e.Pos.Line != last.Line {
+			last = e.Pos
+			(*l)[i] = e
+			i++
+		}
+	}
+	*l = (*l)[0:i]
+}
+
+// An ErrorList implements the error interface.
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Err returns an error equivalent to this error list. If the list is empty,
+// Err returns nil.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
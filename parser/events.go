@@ -1,8 +1,6 @@
 package sanparser
 
 import (
-	"fmt"
-
 	ast "github.com/fgrehm/go-san/ast"
 	token "github.com/fgrehm/go-san/token"
 )
@@ -15,53 +13,63 @@ func parseEvents(f *ast.File, p *parser, eventsToken token.Token) error {
 		Descriptions: []*ast.EventDescription{},
 	}
 	f.Events = eventsDefinition
+	p.comment(&eventsDefinition.LeadComment, &eventsDefinition.LineComment, eventsToken, eventsToken)
 
-	for {
+	for n := 0; ; n++ {
 		tok := p.scan()
 		if tok.Type == token.EOF {
 			break
 		}
 		if !tok.Type.IsEventType() {
-			if len(eventsDefinition.Descriptions) == 0 {
-				return fmt.Errorf("Unexpected token found: %s. Expected an event type ('loc' or 'syn')", tok.String())
+			if n == 0 {
+				return p.unexpected(tok, "an event type", token.LOC, token.SYN)
 			}
 			p.unscan()
 			break
 		}
 
-		descriptionTrace := trace(p, "parseEventDescription")
-		description := &ast.EventDescription{Type: tok}
-
-		tok = p.scan()
-		if tok.Type != token.IDENTIFIER {
-			return fmt.Errorf("Unexpected token found: %s. Expected an identifier", tok.String())
+		description, err := parseEventDescription(p, tok)
+		if err != nil {
+			p.error(err)
+			p.sync(token.LOC, token.SYN)
+			continue
 		}
-		description.Name = tok
+		eventsDefinition.Descriptions = append(eventsDefinition.Descriptions, description)
+	}
 
-		tok = p.scan()
-		if tok.Type != token.LPAREN {
-			return fmt.Errorf("Unexpected token found: %s. Expected a (", tok.String())
-		}
+	return nil
+}
 
-		tok = p.scan()
-		if tok.Type != token.IDENTIFIER {
-			return fmt.Errorf("Unexpected token found: %s. Expected an identifier", tok.String())
-		}
-		description.Rate = tok
+func parseEventDescription(p *parser, typeToken token.Token) (*ast.EventDescription, error) {
+	defer un(trace(p, "parseEventDescription"))
 
-		tok = p.scan()
-		if tok.Type != token.RPAREN {
-			return fmt.Errorf("Unexpected token found: %s. Expected a )", tok.String())
-		}
-		tok = p.scan()
-		if tok.Type != token.SEMICOLON {
-			return fmt.Errorf("Unexpected token found: %s. Expected a ;", tok.String())
-		}
+	description := &ast.EventDescription{Type: typeToken}
 
-		un(descriptionTrace)
+	tok := p.scan()
+	if tok.Type != token.IDENTIFIER {
+		return nil, p.unexpected(tok, "", token.IDENTIFIER)
+	}
+	description.Name = tok
 
-		eventsDefinition.Descriptions = append(eventsDefinition.Descriptions, description)
+	tok = p.scan()
+	if tok.Type != token.LPAREN {
+		return nil, p.unexpected(tok, "", token.LPAREN)
 	}
 
-	return nil
+	rate, err := p.scanExpressionUntil(token.RPAREN)
+	if err != nil {
+		return nil, err
+	}
+	description.Rate = rate
+
+	tok = p.scan()
+	if tok.Type != token.RPAREN {
+		return nil, p.unexpected(tok, "", token.RPAREN)
+	}
+	tok = p.scan()
+	if tok.Type != token.SEMICOLON {
+		return nil, p.unexpected(tok, "", token.SEMICOLON)
+	}
+	p.comment(&description.LeadComment, &description.LineComment, typeToken, tok)
+	return description, nil
 }
@@ -0,0 +1,231 @@
+package sanparser
+
+import (
+	ast "github.com/fgrehm/go-san/ast"
+	token "github.com/fgrehm/go-san/token"
+)
+
+// exprParser builds an expression tree out of a list of tokens previously
+// collected by the parser, honoring operators precedence
+type exprParser struct {
+	p      *parser
+	tokens []token.Token
+	pos    int
+}
+
+// parseExpression returns the expression tree for the given tokens, failing
+// if any token is left unused
+func (p *parser) parseExpression(tokens []token.Token) (ast.Expr, error) {
+	defer un(trace(p, "parseExpression"))
+
+	ep := &exprParser{p: p, tokens: tokens}
+	expr, err := ep.parseCondExpr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := ep.peek(); tok.Type != token.EOF {
+		return nil, p.unexpected(tok, "an operator", operators...)
+	}
+	return expr, nil
+}
+
+// peek returns the next token without consuming it, an EOF token positioned
+// at the last token is returned once all tokens have been consumed
+func (ep *exprParser) peek() token.Token {
+	if ep.pos < len(ep.tokens) {
+		return ep.tokens[ep.pos]
+	}
+	eof := token.Token{Type: token.EOF}
+	if len(ep.tokens) > 0 {
+		eof.Pos = ep.tokens[len(ep.tokens)-1].Pos
+	}
+	return eof
+}
+
+// unexpected returns the error for an unexpected token, which is the token
+// that terminated the expression once all tokens have been consumed
+func (ep *exprParser) unexpected(tok token.Token, want string, expected ...token.Type) *PosError {
+	if tok.Type == token.EOF && ep.pos >= len(ep.tokens) {
+		tok = ep.p.tok
+	}
+	return ep.p.unexpected(tok, want, expected...)
+}
+
+// next consumes and returns the next token
+func (ep *exprParser) next() token.Token {
+	tok := ep.peek()
+	if ep.pos < len(ep.tokens) {
+		ep.pos++
+	}
+	return tok
+}
+
+// parseCondExpr parses a binary expression optionally followed by the
+// `? x : y` branches of a conditional expression
+func (ep *exprParser) parseCondExpr() (ast.Expr, error) {
+	cond, err := ep.parseBinaryExpr(token.LowestPrec + 1)
+	if err != nil {
+		return nil, err
+	}
+	if ep.peek().Type != token.QUESTION {
+		return cond, nil
+	}
+
+	question := ep.next()
+	x, err := ep.parseCondExpr()
+	if err != nil {
+		return nil, err
+	}
+	colon := ep.next()
+	if colon.Type != token.COLON {
+		return nil, ep.unexpected(colon, "", token.COLON)
+	}
+	y, err := ep.parseCondExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.CondExpr{Cond: cond, Question: question, X: x, Colon: colon, Y: y}, nil
+}
+
+func (ep *exprParser) parseBinaryExpr(prec1 int) (ast.Expr, error) {
+	x, err := ep.parseUnaryExpr()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ep.peek()
+		oprec := op.Type.Precedence()
+		if oprec < prec1 {
+			return x, nil
+		}
+		ep.next()
+		y, err := ep.parseBinaryExpr(oprec + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &ast.BinaryExpr{X: x, Op: op, Y: y}
+	}
+}
+
+func (ep *exprParser) parseUnaryExpr() (ast.Expr, error) {
+	switch tok := ep.peek(); tok.Type {
+	case token.NEG, token.SUB:
+		ep.next()
+		x, err := ep.parseUnaryExpr()
+		if err != nil {
+			return nil, err
+		}
+		return &ast.UnaryExpr{Op: tok, X: x}, nil
+	}
+	return ep.parseOperand()
+}
+
+func (ep *exprParser) parseOperand() (ast.Expr, error) {
+	tok := ep.next()
+	switch tok.Type {
+	case token.NUMBER, token.FLOAT:
+		return &ast.BasicLit{Token: tok}, nil
+	case token.IDENTIFIER:
+		index, err := ep.parseIndex()
+		if err != nil {
+			return nil, err
+		}
+		return &ast.Ident{Token: tok, Index: index}, nil
+	case token.ST:
+		name := ep.next()
+		if name.Type != token.IDENTIFIER {
+			return nil, ep.unexpected(name, "an automaton name", token.IDENTIFIER)
+		}
+		index, err := ep.parseIndex()
+		if err != nil {
+			return nil, err
+		}
+		return &ast.StateExpr{St: tok, Automaton: name, Index: index}, nil
+	case token.MIN, token.MAX:
+		return ep.parseCall(tok)
+	case token.NB, token.LST:
+		return ep.parseAggregate(tok)
+	case token.LPAREN:
+		x, err := ep.parseCondExpr()
+		if err != nil {
+			return nil, err
+		}
+		rparen := ep.next()
+		if rparen.Type != token.RPAREN {
+			return nil, ep.unexpected(rparen, "", token.RPAREN)
+		}
+		return &ast.ParenExpr{Lparen: tok, X: x, Rparen: rparen}, nil
+	}
+	return nil, ep.unexpected(tok, "an operand", operandStarts...)
+}
+
+// parseCall parses the arguments of a call to the min or max built-ins
+func (ep *exprParser) parseCall(fun token.Token) (ast.Expr, error) {
+	call := &ast.CallExpr{Fun: fun, Args: []ast.Expr{}}
+	call.Lparen = ep.next()
+	if call.Lparen.Type != token.LPAREN {
+		return nil, ep.unexpected(call.Lparen, "", token.LPAREN)
+	}
+	for {
+		arg, err := ep.parseCondExpr()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = append(call.Args, arg)
+
+		tok := ep.next()
+		if tok.Type == token.RPAREN {
+			call.Rparen = tok
+			return call, nil
+		}
+		if tok.Type != token.COMMA {
+			return nil, ep.unexpected(tok, "", token.COMMA, token.RPAREN)
+		}
+	}
+}
+
+// parseAggregate parses the `[Automata] state` operands of the nb and lst
+// built-ins
+func (ep *exprParser) parseAggregate(op token.Token) (ast.Expr, error) {
+	agg := &ast.AggregateExpr{Op: op}
+	agg.Lbrack = ep.next()
+	if agg.Lbrack.Type != token.LBRACK {
+		return nil, ep.unexpected(agg.Lbrack, "", token.LBRACK)
+	}
+	agg.Automata = ep.next()
+	if agg.Automata.Type != token.IDENTIFIER {
+		return nil, ep.unexpected(agg.Automata, "an automaton name", token.IDENTIFIER)
+	}
+	agg.Rbrack = ep.next()
+	if agg.Rbrack.Type != token.RBRACK {
+		return nil, ep.unexpected(agg.Rbrack, "", token.RBRACK)
+	}
+
+	state := ep.next()
+	if state.Type != token.IDENTIFIER {
+		return nil, ep.unexpected(state, "a state name", token.IDENTIFIER)
+	}
+	index, err := ep.parseIndex()
+	if err != nil {
+		return nil, err
+	}
+	agg.State = &ast.Ident{Token: state, Index: index}
+	return agg, nil
+}
+
+// parseIndex parses the optional `[index]` suffix of automata and states
+// names, nil is returned if no index is present
+func (ep *exprParser) parseIndex() (ast.Expr, error) {
+	if ep.peek().Type != token.LBRACK {
+		return nil, nil
+	}
+	ep.next()
+	index, err := ep.parseCondExpr()
+	if err != nil {
+		return nil, err
+	}
+	if rbrack := ep.next(); rbrack.Type != token.RBRACK {
+		return nil, ep.unexpected(rbrack, "", token.RBRACK)
+	}
+	return index, nil
+}
@@ -1,8 +1,6 @@
 package sanparser
 
 import (
-	"fmt"
-
 	ast "github.com/fgrehm/go-san/ast"
 	token "github.com/fgrehm/go-san/token"
 )
@@ -10,12 +8,12 @@ import (
 func parseIdentifiers(f *ast.File, p *parser, identifiersToken token.Token) error {
 	defer un(trace(p, "parseIdentifiers"))
 
-	var err error
 	idDef := &ast.IdentifiersDefinition{
 		Token:       identifiersToken,
 		Assignments: []*ast.IdentifierAssignment{},
 	}
 	f.Identifiers = idDef
+	p.comment(&idDef.LeadComment, &idDef.LineComment, identifiersToken, identifiersToken)
 
 	for {
 		tok := p.scan()
@@ -26,26 +24,38 @@ func parseIdentifiers(f *ast.File, p *parser, identifiersToken token.Token) erro
 			p.unscan()
 			break
 		}
-		if tok.Type != token.IDENTIFIER {
-			return p.err(tok.Pos, fmt.Errorf("Unexpected token found: %q. Expected an identifier", tok.Text))
-		}
-
-		assignmentTrace := trace(p, "ParseIdentifier")
-		assignment := &ast.IdentifierAssignment{Identifier: tok}
-
-		tok = p.scan()
-		if tok.Type != token.ASSIGN {
-			return p.err(tok.Pos, fmt.Errorf("Unexpected token found: %q. Expected an =", tok.Text))
-		}
 
-		assignment.Expression, err = p.scanExpression()
+		assignment, err := parseIdentifierAssignment(p, tok)
 		if err != nil {
-			return err
+			p.error(err)
+			p.sync()
+			continue
 		}
-		un(assignmentTrace)
-
 		idDef.Assignments = append(idDef.Assignments, assignment)
 	}
 
 	return nil
 }
+
+func parseIdentifierAssignment(p *parser, tok token.Token) (*ast.IdentifierAssignment, error) {
+	defer un(trace(p, "ParseIdentifier"))
+
+	var err error
+	first := tok
+	if tok.Type != token.IDENTIFIER {
+		return nil, p.unexpected(tok, "", token.IDENTIFIER)
+	}
+	assignment := &ast.IdentifierAssignment{Identifier: tok}
+
+	tok = p.scan()
+	if tok.Type != token.ASSIGN {
+		return nil, p.unexpected(tok, "", token.ASSIGN)
+	}
+
+	assignment.Expression, err = p.scanExpression()
+	if err != nil {
+		return nil, err
+	}
+	p.comment(&assignment.LeadComment, &assignment.LineComment, first, p.consumed())
+	return assignment, nil
+}
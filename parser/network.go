@@ -18,30 +18,31 @@ func parseNetwork(f *ast.File, p *parser, networkToken token.Token) error {
 
 	tok := p.scan()
 	if tok.Type != token.IDENTIFIER {
-		return fmt.Errorf("Unexpected token found: %s. Expected an identifier", tok.String())
+		return p.unexpected(tok, "", token.IDENTIFIER)
 	}
 	networkDef.Name = tok
 	tok = p.scan()
 	if tok.Type != token.LPAREN {
-		return fmt.Errorf("Unexpected token found: %s. Expected a (", tok.String())
+		return p.unexpected(tok, "", token.LPAREN)
 	}
 	tok = p.scan()
-	if tok.Type != token.CONTINUOUS {
-		return fmt.Errorf("Unexpected token found: %s. Expected to find the 'continous' keyword", tok.String())
+	if !tok.Type.IsNetworkType() {
+		return p.unexpected(tok, "", token.CONTINUOUS, token.DISCRETE)
 	}
 	networkDef.Type = tok
 
 	tok = p.scan()
 	if tok.Type != token.RPAREN {
-		return fmt.Errorf("Unexpected token found: %s. Expected a )", tok.String())
+		return p.unexpected(tok, "", token.RPAREN)
 	}
+	p.comment(&networkDef.LeadComment, &networkDef.LineComment, networkToken, tok)
 
-	for {
+	for n := 0; ; n++ {
 		descriptionTrace := trace(p, "parseAutomatonDescription")
 		tok = p.scan()
 		if tok.Type != token.AUT {
-			if len(networkDef.Automata) == 0 {
-				return fmt.Errorf("Unexpected token found: %s. Expected to find the 'aut' keyword", tok.String())
+			if n == 0 {
+				return p.unexpected(tok, "", token.AUT)
 			}
 			if tok.Type != token.EOF {
 				p.unscan()
@@ -50,17 +51,20 @@ func parseNetwork(f *ast.File, p *parser, networkToken token.Token) error {
 		}
 		// tok is the aut keyword
 		automatonDesc, err := parseAutomatonDescription(p, tok)
+		un(descriptionTrace)
 		if err != nil {
-			return err
+			p.error(err)
+			p.sync(token.AUT)
+			continue
 		}
 		networkDef.Automata = append(networkDef.Automata, automatonDesc)
-		un(descriptionTrace)
 	}
 
 	return nil
 }
 
 func parseAutomatonDescription(p *parser, autToken token.Token) (*ast.AutomatonDescription, error) {
+	var err error
 	automatonDesc := &ast.AutomatonDescription{
 		Token:       autToken,
 		Transitions: []*ast.AutomatonTransition{},
@@ -68,38 +72,66 @@ func parseAutomatonDescription(p *parser, autToken token.Token) (*ast.AutomatonD
 
 	tok := p.scan()
 	if tok.Type != token.IDENTIFIER {
-		return nil, fmt.Errorf("Unexpected token found: %s. Expected to find an identifier", tok.String())
+		return nil, p.unexpected(tok, "", token.IDENTIFIER)
 	}
 	automatonDesc.Name = tok
 
-	for {
+	tok = p.scan()
+	if tok.Type == token.LBRACK {
+		automatonDesc.Replication, err = parseRange(p, tok)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		p.unscan()
+	}
+	p.comment(&automatonDesc.LeadComment, &automatonDesc.LineComment, autToken, p.consumed())
+
+	for n := 0; ; n++ {
 		tok = p.scan()
 		if tok.Type != token.STT {
-			if len(automatonDesc.Transitions) == 0 {
-				return nil, fmt.Errorf("Unexpected EOF. Expected to find the 'stt' keyword")
+			if n == 0 {
+				return nil, p.unexpected(tok, "", token.STT)
 			}
 			p.unscan()
 			break
 		}
 
 		transitionsTrace := trace(p, "parseAutomatonTransitions")
-		transitions, err := parseAutomatonTransitions(p, tok)
+		state, transitions, err := parseAutomatonTransitions(p, tok)
+		un(transitionsTrace)
 		if err != nil {
-			return nil, err
+			p.error(err)
+			p.sync(token.STT, token.AUT)
+			continue
 		}
+		automatonDesc.States = append(automatonDesc.States, state)
 		automatonDesc.Transitions = append(automatonDesc.Transitions, transitions...)
-		un(transitionsTrace)
 	}
 
 	return automatonDesc, nil
 }
 
-func parseAutomatonTransitions(p *parser, sttToken token.Token) ([]*ast.AutomatonTransition, error) {
+func parseAutomatonTransitions(p *parser, sttToken token.Token) (*ast.AutomatonState, []*ast.AutomatonTransition, error) {
+	var err error
 	from := p.scan()
 	if from.Type != token.IDENTIFIER {
-		return nil, fmt.Errorf("Unexpected token found: %s. Expected to find an identifier", from.String())
+		return nil, nil, p.unexpected(from, "", token.IDENTIFIER)
 	}
 
+	var fromRange *ast.Range
+	tok := p.scan()
+	if tok.Type == token.LBRACK {
+		fromRange, err = parseRange(p, tok)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		p.unscan()
+	}
+
+	state := &ast.AutomatonState{Token: sttToken, Name: from, Range: fromRange}
+	p.comment(&state.LeadComment, &state.LineComment, sttToken, p.consumed())
 	transitions := []*ast.AutomatonTransition{}
 
 	for {
@@ -109,37 +141,68 @@ func parseAutomatonTransitions(p *parser, sttToken token.Token) ([]*ast.Automato
 			break
 		}
 
-		transition := &ast.AutomatonTransition{From: from}
+		transition := &ast.AutomatonTransition{Token: tok, From: from, FromRange: fromRange}
 
 		tok = p.scan()
 		if tok.Type != token.LPAREN {
-			return nil, fmt.Errorf("Unexpected token found: %s. Expected a (", tok.String())
+			return nil, nil, p.unexpected(tok, "", token.LPAREN)
 		}
 
 		tok = p.scan()
 		if tok.Type != token.IDENTIFIER {
-			return nil, fmt.Errorf("Unexpected token found: %s. Expected an identifier", tok.String())
+			return nil, nil, p.unexpected(tok, "", token.IDENTIFIER)
 		}
 		transition.To = tok
 
 		tok = p.scan()
+		if tok.Type == token.LBRACK {
+			transition.ToIndex, err = p.scanExpressionUntil(token.RBRACK)
+			if err != nil {
+				return nil, nil, err
+			}
+			p.scan() // the ]
+			tok = p.scan()
+		}
 		if tok.Type != token.RPAREN {
-			return nil, fmt.Errorf("Unexpected token found: %s. Expected a )", tok.String())
+			return nil, nil, p.unexpected(tok, "", token.RPAREN)
 		}
 
 		events, err := parseAutomatonTransitionEvents(p)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		if len(events) == 0 {
-			return nil, fmt.Errorf("No events found for transition %s", transition.From)
+			return nil, nil, p.unexpected(p.tok, fmt.Sprintf("the events of the transition from %s", transition.From.Text), token.IDENTIFIER)
 		}
 		transition.Events = events
+		p.comment(&transition.LeadComment, &transition.LineComment, transition.Token, p.consumed())
 
 		transitions = append(transitions, transition)
 	}
 
-	return transitions, nil
+	return state, transitions, nil
+}
+
+func parseRange(p *parser, lbrackToken token.Token) (*ast.Range, error) {
+	defer un(trace(p, "parseRange"))
+
+	var err error
+	indexRange := &ast.Range{Lbrack: lbrackToken}
+
+	indexRange.Low, err = p.scanExpressionUntil(token.RANGE, token.RBRACK)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.scan(); tok.Type == token.RANGE {
+		indexRange.High, err = p.scanExpressionUntil(token.RBRACK)
+		if err != nil {
+			return nil, err
+		}
+		p.scan() // the ]
+	}
+	indexRange.Rbrack = p.tok
+
+	return indexRange, nil
 }
 
 func parseAutomatonTransitionEvents(p *parser) ([]*ast.TransitionEventDescription, error) {
@@ -158,15 +221,15 @@ func parseAutomatonTransitionEvents(p *parser) ([]*ast.TransitionEventDescriptio
 			p.unscan()
 			continue
 		}
-		tok = p.scan()
-		if tok.Type != token.IDENTIFIER {
-			return nil, fmt.Errorf("Unexpected token found: %s. Expected an identifier", tok.String())
+		probability, err := p.scanExpressionUntil(token.RPAREN)
+		if err != nil {
+			return nil, err
 		}
-		e.Probability = tok
+		e.Probability = probability
 
 		tok = p.scan()
 		if tok.Type != token.RPAREN {
-			return nil, fmt.Errorf("Unexpected token found: %s. Expected a )", tok.String())
+			return nil, p.unexpected(tok, "", token.RPAREN)
 		}
 	}
 
@@ -15,19 +15,37 @@ type parser struct {
 	// Last read token
 	tok token.Token
 
+	errors ErrorList
+
+	// Token read before the last one
+	prevTok token.Token
+
 	comments    []*ast.CommentGroup
 	leadComment *ast.CommentGroup // last lead comment
 	lineComment *ast.CommentGroup // last line comment
 
+	leads       map[int]*ast.CommentGroup // lead comments by the offset of the token they precede
+	lines       map[int]*ast.CommentGroup // line comments by the offset of the token they follow
+	attachments []attachment
+
 	enableTrace bool
 	indent      int
 	n           int // buffer size (max = 1)
 }
 
+// attachment is a node whose comments get attached once the whole source
+// has been scanned, line comments are only known after the token that
+// follows them is read
+type attachment struct {
+	lead, line  **ast.CommentGroup
+	first, last token.Token
+}
+
 type blockParserFunc func(f *ast.File, p *parser, firstToken token.Token) error
 
 var parserMap = map[token.Type]blockParserFunc{
 	token.IDENTIFIERS:  parseIdentifiers,
+	token.DOMAINS:      parseDomains,
 	token.EVENTS:       parseEvents,
 	token.PARTIAL:      parseReachability,
 	token.REACHABILITY: parseReachability,
@@ -35,6 +53,17 @@ var parserMap = map[token.Type]blockParserFunc{
 	token.RESULTS:      parseResults,
 }
 
+// blockStarts are the types of the tokens that start a block, the keys of
+// parserMap
+var blockStarts = []token.Type{token.IDENTIFIERS, token.DOMAINS, token.EVENTS, token.PARTIAL, token.REACHABILITY, token.NETWORK, token.RESULTS}
+
+// operandStarts are the types of the tokens that start an operand
+var operandStarts = []token.Type{token.IDENTIFIER, token.NUMBER, token.FLOAT, token.LPAREN, token.SUB, token.NEG, token.ST, token.MIN, token.MAX, token.NB, token.LST}
+
+// operators are the types of the tokens that continue an expression after an
+// operand
+var operators = []token.Type{token.OR, token.AND, token.EQUAL, token.NEQUAL, token.LT, token.GT, token.LEQ, token.GEQ, token.SUM, token.SUB, token.MULT, token.DIV, token.MOD, token.QUESTION}
+
 // Parser defines a syntatic parser for SAN models
 type Parser interface {
 	// Parse parses a SAN model into an abstract syntax tree
@@ -49,30 +78,46 @@ func New(src []byte) Parser {
 	}
 }
 
+// NewFile returns a new parser for the provided source, the positions of its
+// nodes and errors refer to filename
+func NewFile(filename string, src []byte) Parser {
+	return &parser{
+		sc: scanner.NewFile(filename, src),
+	}
+}
+
 // Parse returns the fully parsed source and returns the abstract syntax tree.
 func Parse(src []byte) (*ast.File, error) {
 	p := New(src)
 	return p.Parse()
 }
 
+// ParseFile parses the source of the file named filename and returns the
+// abstract syntax tree, whose positions refer to filename.
+func ParseFile(filename string, src []byte) (*ast.File, error) {
+	return NewFile(filename, src).Parse()
+}
+
 // Parse returns the fully parsed source and returns the abstract syntax tree.
+// Parsing goes on past syntax errors, which are reported together as an
+// ErrorList sorted by position with a single error per line.
 func (p *parser) Parse() (*ast.File, error) {
-	var err, scerr error
+	p.errors.Reset()
+	p.leads = map[int]*ast.CommentGroup{}
+	p.lines = map[int]*ast.CommentGroup{}
 	p.sc.Error = func(pos token.Pos, msg string) {
-		scerr = p.err(pos, errors.New(msg))
+		p.errors.Add(pos, errors.New(msg))
 	}
 
-	file, err := p.file()
-	if scerr != nil {
-		return nil, scerr
-	}
-	if err != nil {
+	file := p.file()
+	p.errors.RemoveMultiples()
+	if err := p.errors.Err(); err != nil {
 		return nil, err
 	}
 	return file, nil
 }
 
-func (p *parser) file() (*ast.File, error) {
+func (p *parser) file() *ast.File {
 	file := &ast.File{}
 
 	defer un(trace(p, "ParseFile"))
@@ -80,28 +125,35 @@ func (p *parser) file() (*ast.File, error) {
 	for {
 		tok := p.scan()
 		if tok.Type == token.EOF {
-			return file, nil
+			for _, a := range p.attachments {
+				*a.lead = p.leads[a.first.Pos.Offset]
+				*a.line = p.lines[a.last.Pos.Offset]
+			}
+			file.Comments = p.comments
+			return file
 		}
 		if blockParser, ok := parserMap[tok.Type]; ok {
 			if err := blockParser(file, p, tok); err != nil {
-				return nil, err
+				p.error(err)
+				p.sync()
 			}
 		} else {
-			return nil, p.err(tok.Pos, fmt.Errorf("Unexpected token %q found", tok.Text))
+			p.error(p.unexpected(tok, "a block", blockStarts...))
+			p.sync()
 		}
 	}
 }
 
 func (p *parser) scanExpression() (*ast.Expression, error) {
-	exp := &ast.Expression{[]token.Token{}}
+	exp := &ast.Expression{Tokens: []token.Token{}}
 	for {
 		tok := p.scan()
 		if tok.Type == token.EOF {
-			return nil, p.err(tok.Pos, fmt.Errorf("Unexpected token found: %q. Expected a ;", tok.Text))
+			return nil, p.unexpected(tok, "", token.SEMICOLON)
 		}
 		if tok.Type == token.SEMICOLON {
 			if len(exp.Tokens) == 0 {
-				return nil, p.err(tok.Pos, fmt.Errorf("Invalid expression"))
+				return nil, p.unexpected(tok, "an expression", operandStarts...)
 			}
 			break
 		}
@@ -111,9 +163,58 @@ func (p *parser) scanExpression() (*ast.Expression, error) {
 		}
 		exp.Tokens = append(exp.Tokens, tok)
 	}
+
+	root, err := p.parseExpression(exp.Tokens)
+	if err != nil {
+		return nil, err
+	}
+	exp.Root = root
+	return exp, nil
+}
+
+// scanExpressionUntil collects the tokens of an expression up to one of the
+// given token types, which is left unscanned, and builds its tree
+func (p *parser) scanExpressionUntil(stop ...token.Type) (*ast.Expression, error) {
+	exp := &ast.Expression{Tokens: []token.Token{}}
+	depth := 0
+	for {
+		tok := p.scan()
+		if depth == 0 && hasType(tok, stop) {
+			p.unscan()
+			break
+		}
+		if tok.Type == token.EOF || tok.Type == token.SEMICOLON || tok.Type.IsKeyword() && tok.Type != token.ST {
+			return nil, p.unexpected(tok, "", stop...)
+		}
+		switch tok.Type {
+		case token.LPAREN, token.LBRACK:
+			depth++
+		case token.RPAREN, token.RBRACK:
+			depth--
+		}
+		exp.Tokens = append(exp.Tokens, tok)
+	}
+
+	if len(exp.Tokens) == 0 {
+		return nil, p.unexpected(p.tok, "an expression", operandStarts...)
+	}
+	root, err := p.parseExpression(exp.Tokens)
+	if err != nil {
+		return nil, err
+	}
+	exp.Root = root
 	return exp, nil
 }
 
+func hasType(tok token.Token, types []token.Type) bool {
+	for _, t := range types {
+		if tok.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
 // scan returns the next token from the underlying scanner. If a token has
 // been unscanned then read that instead. In the process, it collects any
 // comment groups encountered, and remembers the last lead and line comments.
@@ -127,6 +228,7 @@ func (p *parser) scan() token.Token {
 	// Otherwise read the next token from the scanner and Save it to the buffer
 	// in case we unscan later.
 	prev := p.tok
+	p.prevTok = prev
 	p.tok = p.sc.Scan()
 
 	if p.tok.Type == token.COMMENT {
@@ -143,6 +245,7 @@ func (p *parser) scan() token.Token {
 				// The next token is on a different line, thus
 				// the last comment group is a line comment.
 				p.lineComment = comment
+				p.lines[prev.Pos.Offset] = comment
 			}
 		}
 
@@ -156,6 +259,7 @@ func (p *parser) scan() token.Token {
 			// The next token is following on the line immediately after the
 			// comment group, thus the last comment group is a lead comment.
 			p.leadComment = comment
+			p.leads[p.tok.Pos.Offset] = comment
 		}
 	}
 
@@ -197,6 +301,20 @@ func (p *parser) consumeCommentGroup(n int) (comments *ast.CommentGroup, endline
 	return
 }
 
+// consumed returns the last token read that has not been unscanned
+func (p *parser) consumed() token.Token {
+	if p.n != 0 {
+		return p.prevTok
+	}
+	return p.tok
+}
+
+// comment records the lead and line comments of a node that spans from the
+// first up to the last token, they are attached once parsing is done
+func (p *parser) comment(lead, line **ast.CommentGroup, first, last token.Token) {
+	p.attachments = append(p.attachments, attachment{lead: lead, line: line, first: first, last: last})
+}
+
 // unscan pushes the previously read token back onto the buffer.
 func (p *parser) unscan() {
 	p.n = 1
@@ -209,6 +327,41 @@ func (p *parser) err(pos token.Pos, err error) *PosError {
 	return &PosError{Pos: pos, Err: err}
 }
 
+// unexpected returns the error for an unexpected token, expected being the
+// types of the tokens that would have been accepted and want their description,
+// which is made up from the types when empty
+func (p *parser) unexpected(tok token.Token, want string, expected ...token.Type) *PosError {
+	return p.err(tok.Pos, &UnexpectedError{Found: tok, Expected: expected, Want: want})
+}
+
+// error records a syntax error, errors without a position are placed at the
+// last read token
+func (p *parser) error(err error) {
+	if e, ok := err.(*PosError); ok {
+		p.errors = append(p.errors, e)
+		return
+	}
+	p.errors.Add(p.tok.Pos, err)
+}
+
+// sync skips tokens after a syntax error up to a synchronization point so
+// that parsing can go on: past the next ;, or up to the next block keyword,
+// the end of the source or one of the given token types, which are left
+// unscanned.
+func (p *parser) sync(stop ...token.Type) {
+	tok := p.tok
+	for {
+		if hasType(tok, blockStarts) || tok.Type == token.EOF || hasType(tok, stop) {
+			p.unscan()
+			return
+		}
+		if tok.Type == token.SEMICOLON && p.n == 0 {
+			return
+		}
+		tok = p.scan()
+	}
+}
+
 func (p *parser) printTrace(a ...interface{}) {
 	if !p.enableTrace {
 		return
@@ -1,12 +1,15 @@
 package sanparser
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"strings"
 	"testing"
 
+	ast "github.com/fgrehm/go-san/ast"
 	token "github.com/fgrehm/go-san/token"
 )
 
@@ -94,6 +97,44 @@ func TestParseIdentifiersDefinition_Error(t *testing.T) {
 	}
 }
 
+// ----------------------------------------------------------------------------
+// Domains block
+
+func TestParseDomainsDefinition(t *testing.T) {
+	src := "domains\n  Procs = [0..N];\n  Slots = [1..2 * K];"
+
+	file, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	equals(t, 1, file.Domains.Token.Pos.Line)
+	domains := []string{}
+	for _, domain := range file.Domains.Definitions {
+		domains = append(domains, fmt.Sprintf("%d:%s=%s..%s", domain.Name.Pos.Line, domain.Name.Text, domain.Range.Low.Root, domain.Range.High.Root))
+	}
+	equals(t, []string{"2:Procs=0..N", "3:Slots=1..2 * K"}, domains)
+}
+
+func TestParseDomainsDefinition_Error(t *testing.T) {
+	var models = []string{
+		"domains",
+		"domains D",
+		"domains D = ;",
+		"domains D = [0..3]",
+		"domains D = [3];",
+		"domains D = 0..3;",
+		"domains D = [0..];",
+	}
+
+	for _, m := range models {
+		_, err := Parse([]byte(m))
+		if err == nil {
+			t.Errorf("Expected to error with %q but did not", m)
+		}
+	}
+}
+
 // ----------------------------------------------------------------------------
 // Events block
 
@@ -136,6 +177,17 @@ func TestParseEventsDefinition(t *testing.T) {
 				},
 			},
 		},
+		{
+			"events\nloc l_srv (mu * (st Server == Busy));\nsyn s_1 (0.5);\nloc l_2 ((a + b) * 2);",
+			parsedEventsDefinition{
+				line: 1,
+				events: []parsedEvent{
+					{line: 2, column: 1, evType: "loc", name: "l_srv", rate: "mu * (st Server == Busy)"},
+					{line: 3, column: 1, evType: "syn", name: "s_1", rate: "0.5"},
+					{line: 4, column: 1, evType: "loc", name: "l_2", rate: "(a + b) * 2"},
+				},
+			},
+		},
 	}
 
 	for _, m := range models {
@@ -155,7 +207,7 @@ func TestParseEventsDefinition(t *testing.T) {
 				column: eventDescription.Type.Pos.Column,
 				name:   eventDescription.Name.Text,
 				evType: eventDescription.Type.Text,
-				rate:   eventDescription.Rate.Text,
+				rate:   eventDescription.Rate.Root.String(),
 			})
 		}
 
@@ -175,6 +227,9 @@ func TestParseEventsDefinition_Error(t *testing.T) {
 		"events syn ;",
 		"events syn foo ;",
 		"events syn foo ();",
+		"events loc foo (bar;",
+		"events loc foo (bar *);",
+		"events loc foo ((bar);",
 	}
 
 	for _, m := range models {
@@ -268,7 +323,7 @@ aut Client
   stt A to (B) s_1
   stt B to (C) s_2
   stt C to (B) s_3(p_1)
-        to (A) s_4(p_2) s_5(p_3)
+        to (A) s_4(p_2) s_5(1 * (p_3 + 0.5))
 aut Server stt D to (e) s_6`
 	expected := parsedNetworkDefinition{
 		line:    1,
@@ -283,7 +338,7 @@ aut Server stt D to (e) s_6`
 					{from: "A", to: "B", events: []string{"s_1|"}},
 					{from: "B", to: "C", events: []string{"s_2|"}},
 					{from: "C", to: "B", events: []string{"s_3|p_1"}},
-					{from: "C", to: "A", events: []string{"s_4|p_2", "s_5|p_3"}},
+					{from: "C", to: "A", events: []string{"s_4|p_2", "s_5|1 * (p_3 + 0.5)"}},
 				},
 			},
 			{
@@ -318,7 +373,11 @@ aut Server stt D to (e) s_6`
 		for _, automatonTransition := range automatonDescription.Transitions {
 			events := []string{}
 			for _, event := range automatonTransition.Events {
-				events = append(events, fmt.Sprintf("%s|%s", event.EventName.Text, event.Probability.Text))
+				probability := ""
+				if event.Probability != nil {
+					probability = event.Probability.Root.String()
+				}
+				events = append(events, fmt.Sprintf("%s|%s", event.EventName.Text, probability))
 			}
 			automaton.transitions = append(automaton.transitions, parsedAutomatonTransition{
 				from:   automatonTransition.From.Text,
@@ -337,6 +396,109 @@ func TestParseNetworkDefinition_Error(t *testing.T) {
 		"network Foo",
 		"network Foo\naut",
 		"network Foo (continous) aut",
+		"network Foo (discrete) aut",
+		"network Foo (stochastic) aut A stt a to (b) e",
+	}
+
+	for _, m := range models {
+		_, err := Parse([]byte(m))
+		if err == nil {
+			t.Errorf("Expected to error with %q but did not", m)
+		}
+	}
+}
+
+func TestParseReplicatedAutomaton(t *testing.T) {
+	src := `network Queue (continuous)
+aut Client[3] stt Idle to (Busy) s_req
+aut Server[N] stt Idle to (Busy) s_req
+aut Worker[1..N] stt Idle to (Busy) s_req
+aut Log stt A to (B) l_log`
+
+	file, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replicas := []string{}
+	for _, automatonDescription := range file.Network.Automata {
+		if automatonDescription.Replication == nil {
+			replicas = append(replicas, "")
+			continue
+		}
+		desc := automatonDescription.Replication.Low.Root.String()
+		if automatonDescription.Replication.High != nil {
+			desc += ".." + automatonDescription.Replication.High.Root.String()
+		}
+		replicas = append(replicas, desc)
+	}
+	equals(t, []string{"3", "N", "1..N", ""}, replicas)
+}
+
+func TestParseReplicatedAutomaton_Error(t *testing.T) {
+	var models = []string{
+		"network Foo (continuous) aut A[ stt a to (b) e",
+		"network Foo (continuous) aut A[] stt a to (b) e",
+		"network Foo (continuous) aut A[2 stt a to (b) e",
+		"network Foo (continuous) aut A[2..] stt a to (b) e",
+		"network Foo (continuous) aut A[..2] stt a to (b) e",
+	}
+
+	for _, m := range models {
+		_, err := Parse([]byte(m))
+		if err == nil {
+			t.Errorf("Expected to error with %q but did not", m)
+		}
+	}
+}
+
+func TestParseRangedStates(t *testing.T) {
+	src := `network Queue (continuous)
+aut Queue
+  stt q[0..K] to (q[i + 1]) l_arr
+              to (q[i]) l_self
+  stt full[3] to (q[0]) l_reset
+  stt empty`
+
+	file, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aut := file.Network.Automata[0]
+	states := []string{}
+	for _, state := range aut.States {
+		desc := state.Name.Text
+		if state.Range != nil {
+			desc += "[" + state.Range.Low.Root.String()
+			if state.Range.High != nil {
+				desc += ".." + state.Range.High.Root.String()
+			}
+			desc += "]"
+		}
+		states = append(states, desc)
+	}
+	equals(t, []string{"q[0..K]", "full[3]", "empty"}, states)
+
+	targets := []string{}
+	for _, transition := range aut.Transitions {
+		target := transition.To.Text
+		if transition.ToIndex != nil {
+			target += "[" + transition.ToIndex.Root.String() + "]"
+		}
+		targets = append(targets, transition.From.Text+">"+target)
+	}
+	equals(t, []string{"q>q[i + 1]", "q>q[i]", "full>q[0]"}, targets)
+}
+
+func TestParseRangedStates_Error(t *testing.T) {
+	var models = []string{
+		"network Foo (continuous) aut A stt a[ to (b) e",
+		"network Foo (continuous) aut A stt a[] to (b) e",
+		"network Foo (continuous) aut A stt a[0..] to (b) e",
+		"network Foo (continuous) aut A stt a[0..2 to (b) e",
+		"network Foo (continuous) aut A stt a to (b[) e",
+		"network Foo (continuous) aut A stt a to (b[1) e",
 	}
 
 	for _, m := range models {
@@ -430,6 +592,158 @@ func TestParseResultsDefinition_Error(t *testing.T) {
 	}
 }
 
+// ----------------------------------------------------------------------------
+// Expressions
+
+func TestParseExpressionTree(t *testing.T) {
+	var testData = []struct {
+		src      string
+		expected string
+	}{
+		{"1", "1"},
+		{"a + b * c", "(a + (b * c))"},
+		{"a * b + c", "((a * b) + c)"},
+		{"a / b / c", "((a / b) / c)"},
+		{"(a + b) * c", "(((a + b)) * c)"},
+		{"!(st Client == Working) * 1", "(!((st Client == Working)) * 1)"},
+		{"st A == s1 && st B != s2", "((st A == s1) && (st B != s2))"},
+		{"!!a", "!!a"},
+		{"st Proc[N * 2] == s1", "(st Proc[(N * 2)] == s1)"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a + 1 < b * 2 == c", "(((a + 1) < (b * 2)) == c)"},
+		{"a >= b || a <= c && a > 0", "((a >= b) || ((a <= c) && (a > 0)))"},
+		{"a % 2 + b", "((a % 2) + b)"},
+		{"a - b - c", "((a - b) - c)"},
+		{"a -1", "(a - 1)"},
+		{"K-1", "(K - 1)"},
+		{"a-b", "(a - b)"},
+		{"(a)-1", "((a) - 1)"},
+		{"q[i-1]", "q[i - 1]"},
+		{"2 * -1", "(2 * -1)"},
+		{"-a * b", "(-a * b)"},
+		{"a ? b : c ? d : e", "a ? b : c ? d : e"},
+		{"a > 0 ? b + 1 : c", "(a > 0) ? (b + 1) : c"},
+		{"min(a, b * 2, 3)", "min(a, (b * 2), 3)"},
+		{"max(a) + 1", "(max(a) + 1)"},
+		{"nb [Clients] Busy * 2", "(nb [Clients] Busy * 2)"},
+		{"lst [Proc] q[i + 1] >= 0", "(lst [Proc] q[(i + 1)] >= 0)"},
+	}
+
+	for _, d := range testData {
+		file, err := Parse([]byte("identifiers x = " + d.src + ";"))
+		if err != nil {
+			t.Errorf("%s: %s", d.src, err)
+			continue
+		}
+		equals(t, d.expected, treeString(file.Identifiers.Assignments[0].Expression.Root))
+	}
+}
+
+func TestParseExpressionTree_Error(t *testing.T) {
+	var models = []string{
+		"identifiers x = (a + b;",
+		"identifiers x = a + ;",
+		"identifiers x = a b;",
+		"identifiers x = st 1;",
+		"identifiers x = * a;",
+		"results a = (st A == s) );",
+		"identifiers x = a ? b;",
+		"identifiers x = a ? b : ;",
+		"identifiers x = a : b;",
+		"identifiers x = min();",
+		"identifiers x = min(a b);",
+		"identifiers x = max a;",
+		"identifiers x = nb Clients Busy;",
+		"identifiers x = nb [Clients];",
+		"identifiers x = nb [1] Busy;",
+		"identifiers x = a | b;",
+	}
+
+	for _, m := range models {
+		_, err := Parse([]byte(m))
+		if err == nil {
+			t.Errorf("Expected to error with %q but did not", m)
+		}
+	}
+}
+
+// treeString renders an expression tree wrapping every binary expression in
+// parenthesis so that precedence can be asserted
+func treeString(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.BinaryExpr:
+		return fmt.Sprintf("(%s %s %s)", treeString(e.X), e.Op.Text, treeString(e.Y))
+	case *ast.UnaryExpr:
+		return e.Op.Text + treeString(e.X)
+	case *ast.ParenExpr:
+		return "(" + treeString(e.X) + ")"
+	case *ast.StateExpr:
+		if e.Index != nil {
+			return "st " + e.Automaton.Text + "[" + treeString(e.Index) + "]"
+		}
+	case *ast.CondExpr:
+		return treeString(e.Cond) + " ? " + treeString(e.X) + " : " + treeString(e.Y)
+	case *ast.CallExpr:
+		args := []string{}
+		for _, arg := range e.Args {
+			args = append(args, treeString(arg))
+		}
+		return e.Fun.Text + "(" + strings.Join(args, ", ") + ")"
+	case *ast.AggregateExpr:
+		if e.State.Index != nil {
+			return e.Op.Text + " [" + e.Automata.Text + "] " + e.State.Token.Text + "[" + treeString(e.State.Index) + "]"
+		}
+	}
+	return e.String()
+}
+
+// ----------------------------------------------------------------------------
+// Comments
+
+func TestParseComments(t *testing.T) {
+	src := `// the identifiers
+identifiers
+  // workers
+  N = 2; // on the farm
+
+  r = 1;
+events
+  loc l_work (r); /* work */
+network Farm (continuous) // farm
+  aut Worker
+    // idle
+    stt Idle to (Busy) l_work // start
+    stt Busy to (Idle) l_work
+// the end
+`
+	file, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := func(g *ast.CommentGroup) string {
+		if g == nil {
+			return ""
+		}
+		list := []string{}
+		for _, c := range g.List {
+			list = append(list, c.Text)
+		}
+		return strings.Join(list, " ")
+	}
+	equals(t, 8, len(file.Comments))
+	equals(t, "// the identifiers", text(file.Identifiers.LeadComment))
+	equals(t, "// workers", text(file.Identifiers.Assignments[0].LeadComment))
+	equals(t, "// on the farm", text(file.Identifiers.Assignments[0].LineComment))
+	equals(t, "", text(file.Identifiers.Assignments[1].LeadComment))
+	equals(t, "/* work */", text(file.Events.Descriptions[0].LineComment))
+	equals(t, "// farm", text(file.Network.LineComment))
+	equals(t, "// idle", text(file.Network.Automata[0].States[0].LeadComment))
+	equals(t, "// start", text(file.Network.Automata[0].Transitions[0].LineComment))
+	equals(t, "", text(file.Network.Automata[0].Transitions[1].LineComment))
+	equals(t, 14, file.Comments[7].EndLine())
+}
+
 // ----------------------------------------------------------------------------
 // Bad models
 
@@ -444,6 +758,100 @@ func TestErrorsWhenUnexpectedTokenFoundAtRoot(t *testing.T) {
 	}
 }
 
+func TestParse_ErrorRecovery(t *testing.T) {
+	src := `identifiers
+  a = 1;
+  b = ;
+  c = 2 +;
+  d = 4;
+domains
+  D = [0..];
+events
+  loc e1 (1)
+  loc e2 (a);
+  syn e3 (;
+network N (continuous)
+  aut A
+    stt s1 to (s2) e1
+    stt s2 to ( e2
+    stt s3 to (s1) e2
+  aut B
+    stt x to [ e1
+results
+  r1 = st A == s1;
+  2 = 3; 4 = 5;
+  r3 = ) ;
+`
+	_, err := Parse([]byte(src))
+	list, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("Expected an ErrorList, got %#v", err)
+	}
+	positions := []string{}
+	for _, e := range list {
+		positions = append(positions, e.Pos.String())
+	}
+	equals(t, []string{"3:7", "4:10", "7:11", "10:3", "11:11", "16:5", "18:14", "21:3", "22:8"}, positions)
+	equals(t, `At 3:7: Unexpected token found: ";". Expected an expression (and 8 more errors)`, err.Error())
+}
+
+func TestParse_UnexpectedError(t *testing.T) {
+	var testData = []struct {
+		src      string
+		pos      string
+		found    token.Type
+		expected []token.Type
+		message  string
+	}{
+		{"identifiers x 1;", "1:15", token.NUMBER, []token.Type{token.ASSIGN}, `Unexpected token found: "1". Expected an =`},
+		{"events loc e (1)", "1:17", token.EOF, []token.Type{token.SEMICOLON}, "Unexpected end of file. Expected a ;"},
+		{"network N (fast)", "1:12", token.IDENTIFIER, []token.Type{token.CONTINUOUS, token.DISCRETE}, `Unexpected token found: "fast". Expected the 'continuous' keyword or the 'discrete' keyword`},
+		{"network N (continuous) aut A results", "1:30", token.RESULTS, []token.Type{token.STT}, `Unexpected token found: "results". Expected the 'stt' keyword`},
+		{"partial = 1;", "1:9", token.ASSIGN, []token.Type{token.REACHABILITY}, `Unexpected token found: "=". Expected the 'reachability' keyword`},
+		{"results r = min(a b);", "1:19", token.IDENTIFIER, []token.Type{token.COMMA, token.RPAREN}, `Unexpected token found: "b". Expected a , or a )`},
+	}
+
+	for _, d := range testData {
+		_, err := Parse([]byte(d.src))
+		list, ok := err.(ErrorList)
+		if !ok || len(list) != 1 {
+			t.Errorf("Expected a single error for %q, got %v", d.src, err)
+			continue
+		}
+		e, ok := list[0].Err.(*UnexpectedError)
+		if !ok {
+			t.Errorf("Expected an UnexpectedError for %q, got %#v", d.src, list[0].Err)
+			continue
+		}
+		equals(t, d.pos, list[0].Pos.String())
+		equals(t, d.found, e.Found.Type)
+		equals(t, d.expected, e.Expected)
+		equals(t, d.message, e.Error())
+	}
+}
+
+func TestErrorList(t *testing.T) {
+	var list ErrorList
+	if list.Err() != nil {
+		t.Errorf("Expected no error for an empty list, got %v", list.Err())
+	}
+	list.Add(token.Pos{Line: 2, Column: 5}, errors.New("b"))
+	list.Add(token.Pos{Line: 1, Column: 3}, errors.New("c"))
+	list.Add(token.Pos{Line: 2, Column: 1}, errors.New("a"))
+	list.Add(token.Pos{Line: 1, Column: 3}, errors.New("a"))
+
+	list.Sort()
+	messages := []string{}
+	for _, e := range list {
+		messages = append(messages, e.Error())
+	}
+	equals(t, []string{"At 1:3: a", "At 1:3: c", "At 2:1: a", "At 2:5: b"}, messages)
+
+	list.RemoveMultiples()
+	equals(t, 2, list.Len())
+	equals(t, "At 1:3: a (and 1 more errors)", list.Err().Error())
+}
+
 // ----------------------------------------------------------------------------
 // Utilities
 
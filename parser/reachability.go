@@ -1,8 +1,6 @@
 package sanparser
 
 import (
-	"fmt"
-
 	ast "github.com/fgrehm/go-san/ast"
 	token "github.com/fgrehm/go-san/token"
 )
@@ -19,7 +17,7 @@ func parseReachability(f *ast.File, p *parser, firstToken token.Token) error {
 	tok := p.scan()
 	if firstToken.Type == token.PARTIAL {
 		if tok.Type != token.REACHABILITY {
-			return fmt.Errorf("Unexpected token found: %s. Expected to find 'reachability'", tok.String())
+			return p.unexpected(tok, "", token.REACHABILITY)
 		}
 		reachabilityDef.Tokens = append(reachabilityDef.Tokens, tok)
 	} else {
@@ -28,13 +26,14 @@ func parseReachability(f *ast.File, p *parser, firstToken token.Token) error {
 
 	tok = p.scan()
 	if tok.Type != token.ASSIGN {
-		return fmt.Errorf("Unexpected token found: %s. Expected an =", tok.String())
+		return p.unexpected(tok, "", token.ASSIGN)
 	}
 
 	reachabilityDef.Expression, err = p.scanExpression()
 	if err != nil {
 		return err
 	}
+	p.comment(&reachabilityDef.LeadComment, &reachabilityDef.LineComment, firstToken, p.consumed())
 
 	return nil
 }
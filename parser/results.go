@@ -1,8 +1,6 @@
 package sanparser
 
 import (
-	"fmt"
-
 	ast "github.com/fgrehm/go-san/ast"
 	token "github.com/fgrehm/go-san/token"
 )
@@ -10,19 +8,18 @@ import (
 func parseResults(f *ast.File, p *parser, resultsToken token.Token) error {
 	defer un(trace(p, "parseResults"))
 
-	var err error
-
 	resultsDef := &ast.ResultsDefinition{
 		Token:        resultsToken,
 		Descriptions: []*ast.ResultDescription{},
 	}
 	f.Results = resultsDef
+	p.comment(&resultsDef.LeadComment, &resultsDef.LineComment, resultsToken, resultsToken)
 
-	for {
+	for n := 0; ; n++ {
 		tok := p.scan()
 		if tok.Type == token.EOF {
-			if len(resultsDef.Descriptions) == 0 {
-				return fmt.Errorf("Expected to find a list of results")
+			if n == 0 {
+				return p.unexpected(tok, "a list of results", token.IDENTIFIER)
 			}
 			break
 		}
@@ -30,26 +27,38 @@ func parseResults(f *ast.File, p *parser, resultsToken token.Token) error {
 			p.unscan()
 			break
 		}
-		if tok.Type != token.IDENTIFIER {
-			return fmt.Errorf("Unexpected token found: %s. Expected an identifier", tok.String())
-		}
-
-		resultTrace := trace(p, "parseResultDescription")
-		result := &ast.ResultDescription{Label: tok}
 
-		tok = p.scan()
-		if tok.Type != token.ASSIGN {
-			return fmt.Errorf("Unexpected token found: %s. Expected an =", tok.String())
-		}
-
-		result.Expression, err = p.scanExpression()
+		result, err := parseResultDescription(p, tok)
 		if err != nil {
-			return err
+			p.error(err)
+			p.sync()
+			continue
 		}
-		un(resultTrace)
-
 		resultsDef.Descriptions = append(resultsDef.Descriptions, result)
 	}
 
 	return nil
 }
+
+func parseResultDescription(p *parser, tok token.Token) (*ast.ResultDescription, error) {
+	defer un(trace(p, "parseResultDescription"))
+
+	var err error
+	first := tok
+	if tok.Type != token.IDENTIFIER {
+		return nil, p.unexpected(tok, "", token.IDENTIFIER)
+	}
+	result := &ast.ResultDescription{Label: tok}
+
+	tok = p.scan()
+	if tok.Type != token.ASSIGN {
+		return nil, p.unexpected(tok, "", token.ASSIGN)
+	}
+
+	result.Expression, err = p.scanExpression()
+	if err != nil {
+		return nil, err
+	}
+	p.comment(&result.LeadComment, &result.LineComment, first, p.consumed())
+	return result, nil
+}
@@ -0,0 +1,316 @@
+// Package sanprinter implements printing of SAN abstract syntax trees in a
+// canonical format, comments included.
+package sanprinter
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+
+	ast "github.com/fgrehm/go-san/ast"
+	parser "github.com/fgrehm/go-san/parser"
+	token "github.com/fgrehm/go-san/token"
+)
+
+// Fprint pretty prints a SAN file to w. Blocks are kept in source order with
+// their definitions one per line, indented by two spaces for each level of
+// nesting, and expressions are written in their canonical form. The lead
+// comment of a definition is printed on its own lines before it and its line
+// comment at the end of its line. The comments that are not attached to any
+// definition are kept in place, at the end of the line of the definition
+// they are found in or on their own lines otherwise. Sequences of blank lines
+// are reduced to one.
+func Fprint(w io.Writer, f *ast.File) error {
+	p := &printer{comments: detached(f)}
+	for _, b := range blocks(f) {
+		b.print(p)
+	}
+	p.flush(token.Pos{Offset: -1}, "")
+	_, err := w.Write(p.buf.Bytes())
+	return err
+}
+
+// Format parses src and returns it formatted by Fprint
+func Format(src []byte) ([]byte, error) {
+	f, err := parser.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err := Fprint(buf, f); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type printer struct {
+	buf      bytes.Buffer
+	comments []*ast.CommentGroup // the detached comments yet to be printed
+	line     int                 // the last source line printed
+}
+
+// block is a block of a file along with the position of its first token
+type block struct {
+	pos   token.Pos
+	print func(p *printer)
+}
+
+// blocks returns the blocks of a file in source order
+func blocks(f *ast.File) []block {
+	list := []block{}
+	if d := f.Identifiers; d != nil {
+		list = append(list, block{d.Token.Pos, func(p *printer) {
+			p.print("", d.Token.Pos, d.Token.Pos, d.Token.Text, d.LeadComment, d.LineComment)
+			for _, a := range d.Assignments {
+				p.print("  ", a.Identifier.Pos, end(a.Expression), a.Identifier.Text+" = "+a.Expression.Root.String()+";", a.LeadComment, a.LineComment)
+			}
+		}})
+	}
+	if d := f.Domains; d != nil {
+		list = append(list, block{d.Token.Pos, func(p *printer) {
+			p.print("", d.Token.Pos, d.Token.Pos, d.Token.Text, d.LeadComment, d.LineComment)
+			for _, def := range d.Definitions {
+				p.print("  ", def.Name.Pos, def.Range.Rbrack.Pos, def.Name.Text+" = "+rangeString(def.Range)+";", def.LeadComment, def.LineComment)
+			}
+		}})
+	}
+	if d := f.Events; d != nil {
+		list = append(list, block{d.Token.Pos, func(p *printer) {
+			p.print("", d.Token.Pos, d.Token.Pos, d.Token.Text, d.LeadComment, d.LineComment)
+			for _, e := range d.Descriptions {
+				p.print("  ", e.Type.Pos, end(e.Rate), e.Type.Text+" "+e.Name.Text+" ("+e.Rate.Root.String()+");", e.LeadComment, e.LineComment)
+			}
+		}})
+	}
+	if d := f.Reachability; d != nil {
+		list = append(list, block{d.Tokens[0].Pos, func(p *printer) {
+			words := []string{}
+			for _, tok := range d.Tokens {
+				words = append(words, tok.Text)
+			}
+			p.print("", d.Tokens[0].Pos, end(d.Expression), strings.Join(words, " ")+" = "+d.Expression.Root.String()+";", d.LeadComment, d.LineComment)
+		}})
+	}
+	if d := f.Network; d != nil {
+		list = append(list, block{d.Token.Pos, func(p *printer) {
+			p.print("", d.Token.Pos, d.Type.Pos, d.Token.Text+" "+d.Name.Text+" ("+d.Type.Text+")", d.LeadComment, d.LineComment)
+			for _, aut := range d.Automata {
+				printAutomaton(p, aut)
+			}
+		}})
+	}
+	if d := f.Results; d != nil {
+		list = append(list, block{d.Token.Pos, func(p *printer) {
+			p.print("", d.Token.Pos, d.Token.Pos, d.Token.Text, d.LeadComment, d.LineComment)
+			for _, r := range d.Descriptions {
+				p.print("  ", r.Label.Pos, end(r.Expression), r.Label.Text+" = "+r.Expression.Root.String()+";", r.LeadComment, r.LineComment)
+			}
+		}})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].pos.Offset < list[j].pos.Offset })
+	return list
+}
+
+// printAutomaton prints an automaton with the first transition of each state
+// on the line of the state and the others aligned below it
+func printAutomaton(p *printer, aut *ast.AutomatonDescription) {
+	name, last := aut.Name.Text, aut.Name.Pos
+	if aut.Replication != nil {
+		name += rangeString(aut.Replication)
+		last = aut.Replication.Rbrack.Pos
+	}
+	p.print("  ", aut.Token.Pos, last, aut.Token.Text+" "+name, aut.LeadComment, aut.LineComment)
+
+	for _, state := range aut.States {
+		text, last := state.Token.Text+" "+state.Name.Text, state.Name.Pos
+		if state.Range != nil {
+			text += rangeString(state.Range)
+			last = state.Range.Rbrack.Pos
+		}
+		transitions := []*ast.AutomatonTransition{}
+		for _, t := range aut.Transitions {
+			if t.From.Pos == state.Name.Pos {
+				transitions = append(transitions, t)
+			}
+		}
+		if len(transitions) == 0 {
+			p.print("    ", state.Token.Pos, last, text, state.LeadComment, state.LineComment)
+			continue
+		}
+		p.print("    ", state.Token.Pos, transitionEnd(transitions[0]), text+" "+transitionString(transitions[0]), state.LeadComment, state.LineComment, transitions[0].LeadComment, transitions[0].LineComment)
+		indent := "    " + strings.Repeat(" ", len(text)+1)
+		for _, t := range transitions[1:] {
+			p.print(indent, t.Token.Pos, transitionEnd(t), transitionString(t), t.LeadComment, t.LineComment)
+		}
+	}
+}
+
+func transitionString(t *ast.AutomatonTransition) string {
+	to := t.To.Text
+	if t.ToIndex != nil {
+		to += "[" + t.ToIndex.Root.String() + "]"
+	}
+	events := []string{}
+	for _, e := range t.Events {
+		event := e.EventName.Text
+		if e.Probability != nil {
+			event += "(" + e.Probability.Root.String() + ")"
+		}
+		events = append(events, event)
+	}
+	return t.Token.Text + " (" + to + ") " + strings.Join(events, " ")
+}
+
+// transitionEnd returns the position of the last token of a transition
+func transitionEnd(t *ast.AutomatonTransition) token.Pos {
+	e := t.Events[len(t.Events)-1]
+	if e.Probability != nil {
+		return end(e.Probability)
+	}
+	return e.EventName.Pos
+}
+
+func rangeString(r *ast.Range) string {
+	if r.High == nil {
+		return "[" + r.Low.Root.String() + "]"
+	}
+	return "[" + r.Low.Root.String() + ".." + r.High.Root.String() + "]"
+}
+
+// end returns the position of the last token of an expression
+func end(e *ast.Expression) token.Pos {
+	return e.Tokens[len(e.Tokens)-1].Pos
+}
+
+// print prints a line of text that stands for the tokens from first up to
+// last, preceded by its lead comment and followed by the trailing comments,
+// which are the line comments of the nodes printed on the line. The detached
+// comments found before the line are printed on their own lines, the ones
+// found up to the line of last are printed at its end.
+func (p *printer) print(indent string, first, last token.Pos, text string, lead *ast.CommentGroup, trailing ...*ast.CommentGroup) {
+	if lead != nil {
+		p.flush(lead.Pos(), indent)
+		p.space(lead.Pos().Line)
+		p.buf.WriteString(indent)
+		p.group(lead, indent)
+		p.buf.WriteString("\n")
+	} else {
+		p.flush(first, indent)
+	}
+	p.space(first.Line)
+	p.buf.WriteString(indent + text)
+	p.line = last.Line
+
+	groups := []*ast.CommentGroup{}
+	for _, g := range trailing {
+		if g != nil {
+			groups = append(groups, g)
+		}
+	}
+	for len(p.comments) > 0 && p.comments[0].Pos().Line <= last.Line {
+		groups = append(groups, p.comments[0])
+		p.comments = p.comments[1:]
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Pos().Offset < groups[j].Pos().Offset })
+	for _, g := range groups {
+		p.buf.WriteString(" ")
+		p.group(g, indent)
+	}
+	p.buf.WriteString("\n")
+}
+
+// flush prints the detached comments found before pos on their own lines, all
+// of them if the offset of pos is negative
+func (p *printer) flush(pos token.Pos, indent string) {
+	for len(p.comments) > 0 && (pos.Offset < 0 || p.comments[0].Pos().Offset < pos.Offset) {
+		g := p.comments[0]
+		p.comments = p.comments[1:]
+		p.space(g.Pos().Line)
+		p.buf.WriteString(indent)
+		p.group(g, indent)
+		p.buf.WriteString("\n")
+	}
+}
+
+// group prints a comment group, comments that share a line are kept together
+// and the others are indented by indent
+func (p *printer) group(g *ast.CommentGroup, indent string) {
+	for i, c := range g.List {
+		if i > 0 {
+			if c.Start.Line == g.List[i-1].Start.Line {
+				p.buf.WriteString(" ")
+			} else {
+				p.buf.WriteString("\n" + indent)
+			}
+		}
+		p.buf.WriteString(c.Text)
+	}
+	p.line = g.EndLine()
+}
+
+// space prints a blank line if the source had any between the last line
+// printed and line
+func (p *printer) space(line int) {
+	if p.buf.Len() > 0 && line > p.line+1 {
+		p.buf.WriteString("\n")
+	}
+}
+
+// detached returns the comments of a file that are not attached to any node
+// as a lead or line comment, in source order
+func detached(f *ast.File) []*ast.CommentGroup {
+	attached := map[*ast.CommentGroup]bool{}
+	attach := func(groups ...*ast.CommentGroup) {
+		for _, g := range groups {
+			attached[g] = true
+		}
+	}
+	if d := f.Identifiers; d != nil {
+		attach(d.LeadComment, d.LineComment)
+		for _, a := range d.Assignments {
+			attach(a.LeadComment, a.LineComment)
+		}
+	}
+	if d := f.Domains; d != nil {
+		attach(d.LeadComment, d.LineComment)
+		for _, def := range d.Definitions {
+			attach(def.LeadComment, def.LineComment)
+		}
+	}
+	if d := f.Events; d != nil {
+		attach(d.LeadComment, d.LineComment)
+		for _, e := range d.Descriptions {
+			attach(e.LeadComment, e.LineComment)
+		}
+	}
+	if d := f.Reachability; d != nil {
+		attach(d.LeadComment, d.LineComment)
+	}
+	if d := f.Network; d != nil {
+		attach(d.LeadComment, d.LineComment)
+		for _, aut := range d.Automata {
+			attach(aut.LeadComment, aut.LineComment)
+			for _, state := range aut.States {
+				attach(state.LeadComment, state.LineComment)
+			}
+			for _, t := range aut.Transitions {
+				attach(t.LeadComment, t.LineComment)
+			}
+		}
+	}
+	if d := f.Results; d != nil {
+		attach(d.LeadComment, d.LineComment)
+		for _, r := range d.Descriptions {
+			attach(r.LeadComment, r.LineComment)
+		}
+	}
+
+	list := []*ast.CommentGroup{}
+	for _, g := range f.Comments {
+		if !attached[g] {
+			list = append(list, g)
+		}
+	}
+	return list
+}
@@ -0,0 +1,127 @@
+package sanprinter
+
+import (
+	"bytes"
+	"testing"
+
+	parser "github.com/fgrehm/go-san/parser"
+)
+
+func TestFormat(t *testing.T) {
+	var testData = []struct {
+		src      string
+		expected string
+	}{
+		{
+			"identifiers N=2;r = 1.5 ;\ndomains D = [ 0 .. N ];",
+			"identifiers\n  N = 2;\n  r = 1.5;\ndomains\n  D = [0..N];\n",
+		},
+		{
+			"events loc l_work ( r*2 ); syn l_sync (1);\npartial reachability = st A == a\n  && st B != b;",
+			"events\n  loc l_work (r * 2);\n  syn l_sync (1);\npartial reachability = st A == a && st B != b;\n",
+		},
+		{
+			"network Farm (continuous) aut Worker[N] stt Idle to (Busy) l_work stt Busy to (Idle) l_work to (Busy) l_sync(0.5) l_work\n" +
+				"aut Queue stt q[0..K]\n  to (q[i+1]) l_arr",
+			"network Farm (continuous)\n" +
+				"  aut Worker[N]\n" +
+				"    stt Idle to (Busy) l_work\n" +
+				"    stt Busy to (Idle) l_work\n" +
+				"             to (Busy) l_sync(0.5) l_work\n" +
+				"  aut Queue\n" +
+				"    stt q[0..K] to (q[i + 1]) l_arr\n",
+		},
+		{
+			"results busy = nb [Worker] Busy;\n\n\n\nidentifiers x = a ? b : c;",
+			"results\n  busy = nb [Worker] Busy;\n\nidentifiers\n  x = a ? b : c;\n",
+		},
+		{
+			`// Model header
+
+// the identifiers
+identifiers
+  N = 2; // number of workers
+  /* the
+     rate */
+  r = 1;
+events
+  loc l_work (r); /* work */ // done
+network Farm (continuous)
+  aut Worker
+    // idle
+    stt Idle // waiting
+      to (Busy) l_work // start
+    stt Busy to (Idle) l_work
+// the end
+`,
+			`// Model header
+
+// the identifiers
+identifiers
+  N = 2; // number of workers
+  /* the
+     rate */
+  r = 1;
+events
+  loc l_work (r); /* work */ // done
+network Farm (continuous)
+  aut Worker
+    // idle
+    stt Idle to (Busy) l_work // waiting // start
+    stt Busy to (Idle) l_work
+// the end
+`,
+		},
+	}
+
+	for _, d := range testData {
+		out, err := Format([]byte(d.src))
+		if err != nil {
+			t.Errorf("%s: %s", d.src, err)
+			continue
+		}
+		if string(out) != d.expected {
+			t.Errorf("want:\n%s\ngot:\n%s", d.expected, out)
+		}
+		again, err := Format(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(again, out) {
+			t.Errorf("Expected formatting to be idempotent, got:\n%s\nthen:\n%s", out, again)
+		}
+	}
+}
+
+func TestFprint(t *testing.T) {
+	f, err := parser.Parse([]byte("results\n  a = 1; // one\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := Fprint(buf, f); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "results\n  a = 1; // one\n" {
+		t.Errorf("Unexpected output %q", buf.String())
+	}
+
+	// comments are printed along with the nodes they are attached to
+	f, err = parser.Parse([]byte("results\n  a = 1; // one\n  b = 2;\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := f.Results.Descriptions[0], f.Results.Descriptions[1]
+	a.LineComment, b.LineComment = nil, a.LineComment
+	buf.Reset()
+	if err := Fprint(buf, f); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "results\n  a = 1;\n  b = 2; // one\n" {
+		t.Errorf("Unexpected output %q", buf.String())
+	}
+
+	if _, err := Format([]byte("results a = ;")); err == nil {
+		t.Error("Expected to error on an invalid source")
+	}
+}
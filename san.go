@@ -2,9 +2,14 @@ package san
 
 import (
 	"bytes"
+	"io"
+	"io/ioutil"
 
+	ast "github.com/fgrehm/go-san/ast"
+	saneval "github.com/fgrehm/go-san/eval"
 	model "github.com/fgrehm/go-san/model"
 	parser "github.com/fgrehm/go-san/parser"
+	token "github.com/fgrehm/go-san/token"
 )
 
 // Parse parses a textual san model into a machine friendly structure
@@ -13,7 +18,49 @@ func Parse(src []byte) (*model.Model, error) {
 	if err != nil {
 		return nil, err
 	}
-	return translateAstToModel(file), nil
+	return translateAstToModel(file)
+}
+
+// ParseFile parses the textual san model stored at path, the positions of the
+// model and of any error refer to path
+func ParseFile(path string) (*model.Model, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseFile(path, src)
+}
+
+// ParseReader parses the textual san model read from r, the positions of the
+// model and of any error refer to name
+func ParseReader(name string, r io.Reader) (*model.Model, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseFile(name, src)
+}
+
+// parseFile parses src as the contents of the file named filename
+func parseFile(filename string, src []byte) (*model.Model, error) {
+	file, err := parser.ParseFile(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	return translateFile(filename, file)
+}
+
+// translateFile translates the syntax tree of the file named filename into a
+// model, errors without a position are given one that only refers to the file
+func translateFile(filename string, file *ast.File) (*model.Model, error) {
+	m, err := translateAstToModel(file)
+	switch err.(type) {
+	case nil:
+		return m, nil
+	case *parser.PosError, *saneval.Error:
+		return nil, err
+	}
+	return nil, &parser.PosError{Pos: token.Pos{Filename: filename}, Err: err}
 }
 
 // Compile generates a textual san model based on a sanmodel.Model
@@ -0,0 +1,498 @@
+package san
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	parser "github.com/fgrehm/go-san/parser"
+)
+
+func TestParseReplicatedAutomata(t *testing.T) {
+	src := `identifiers
+  N = 2;
+  r = 1;
+events
+  loc l_work (r);
+reachability = 1;
+network Farm (continuous)
+  aut Worker[N]
+    stt Idle to (Busy) l_work
+    stt Busy to (Idle) l_work
+  aut Monitor[1]
+    stt Watching to (Watching) l_work
+results
+  busy = st Worker[1] == Busy;
+`
+	m, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	groups := []string{}
+	for _, aut := range m.Network.Automata {
+		names = append(names, aut.Name)
+		groups = append(groups, aut.Group)
+	}
+	assertEqual(t, []string{"Worker[0]", "Worker[1]", "Monitor[0]"}, names)
+	assertEqual(t, []string{"Worker", "Worker", "Monitor"}, groups)
+
+	compiled, err := Compile(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recompiled, err := Parse(compiled)
+	if err != nil {
+		t.Fatalf("%s\n%s", err, compiled)
+	}
+	assertEqual(t, len(m.Network.Automata), len(recompiled.Network.Automata))
+	for i, aut := range recompiled.Network.Automata {
+		assertEqual(t, names[i], aut.Name)
+	}
+}
+
+func TestParseReplicatedAutomata_Error(t *testing.T) {
+	var models = []string{
+		"network Foo (continuous) aut A[0] stt a to (b) e",
+		"network Foo (continuous) aut A[N] stt a to (b) e",
+		"identifiers N = 1.5; network Foo (continuous) aut A[N] stt a to (b) e",
+	}
+
+	for _, m := range models {
+		_, err := Parse([]byte(m))
+		if err == nil {
+			t.Errorf("Expected to error with %q but did not", m)
+		}
+	}
+}
+
+func TestParseRangedStates(t *testing.T) {
+	src := `identifiers
+  K = 2;
+  r = 1;
+events
+  loc l_arr (r);
+  loc l_dep (r);
+  loc l_reset (r);
+reachability = 1;
+network Queue (continuous)
+  aut Queue
+    stt q[0..K] to (q[i + 1]) l_arr
+                to (q[i-1]) l_dep
+    stt overflow[K] to (q[0]) l_reset
+results
+  empty = st Queue == q[0];
+`
+	m, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errs := Validate(m); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+
+	aut := m.Network.Automata[0]
+	assertEqual(t, []string{"q[0]", "q[1]", "q[2]", "overflow[2]"}, aut.States)
+
+	transitions := []string{}
+	for _, transition := range aut.Transitions {
+		transitions = append(transitions, transition.From+">"+transition.To)
+	}
+	assertEqual(t, []string{"q[0]>q[1]", "q[1]>q[2]", "q[1]>q[0]", "q[2]>q[1]", "overflow[2]>q[0]"}, transitions)
+
+	compiled, err := Compile(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recompiled, err := Parse(compiled)
+	if err != nil {
+		t.Fatalf("%s\n%s", err, compiled)
+	}
+	assertEqual(t, aut.States, recompiled.Network.Automata[0].States)
+	assertEqual(t, len(aut.Transitions), len(recompiled.Network.Automata[0].Transitions))
+}
+
+func TestParseRangedStates_Error(t *testing.T) {
+	var testData = []struct {
+		src      string
+		expected string
+	}{
+		{"identifiers i = 5; network Foo (continuous) aut A stt q[0..2] to (q[i]) e", `At 1:69: Identifier "i" clashes with the index of ranged states`},
+		{"network Foo (continuous) aut A stt q[0..2] to (q[3]) e", `At 1:50: Transition from "q[0]" of automaton "A" leads to undeclared state "q[3]"`},
+		{"network Foo (continuous) aut A stt q[0..2] stt a to (q[3]) e", `At 1:56: Transition from "a" of automaton "A" leads to undeclared state "q[3]"`},
+		{"network Foo (continuous) aut A stt q[0] stt q[2] stt a[0..2] to (q[i]) e", `At 1:68: Transition from "a[1]" of automaton "A" leads to undeclared state "q[1]"`},
+		{"network Foo (continuous) aut A stt q[0..2] to (r[i]) e", `At 1:50: Transition from "q[0]" of automaton "A" leads to undeclared state "r[0]"`},
+	}
+
+	for _, d := range testData {
+		_, err := Parse([]byte(d.src))
+		if err == nil {
+			t.Errorf("Expected to error with %q but did not", d.src)
+			continue
+		}
+		assertEqual(t, d.expected, err.Error())
+	}
+}
+
+func TestParseRangedStates_Targets(t *testing.T) {
+	var testData = []struct {
+		src      string
+		expected []string
+	}{
+		{"aut A stt q[0..3] to (q[i + 2]) e", []string{"q[0]>q[2]", "q[1]>q[3]"}},
+		{"aut A stt q[0..3] to (q[i - 1]) e stt a to (q[3]) e", []string{"q[1]>q[0]", "q[2]>q[1]", "q[3]>q[2]", "a>q[3]"}},
+		// identifiers named after the index are fine as long as the index
+		// expressions do not refer to them
+		{"identifiers i = 7; aut A stt q[0..3] to (q[0]) e", []string{"q[0]>q[0]", "q[1]>q[0]", "q[2]>q[0]", "q[3]>q[0]"}},
+	}
+
+	for _, d := range testData {
+		src := strings.Replace(d.src, "aut A", "network Foo (continuous) aut A", 1)
+		m, err := Parse([]byte(src))
+		if err != nil {
+			t.Errorf("%s: %s", d.src, err)
+			continue
+		}
+		transitions := []string{}
+		for _, transition := range m.Network.Automata[0].Transitions {
+			transitions = append(transitions, transition.From+">"+transition.To)
+		}
+		assertEqual(t, d.expected, transitions)
+	}
+}
+
+// assertEqual fails the test if exp is not equal to act.
+func assertEqual(tb testing.TB, exp, act interface{}) {
+	tb.Helper()
+	if !reflect.DeepEqual(exp, act) {
+		tb.Fatalf("\n\texp: %#v\n\n\tgot: %#v", exp, act)
+	}
+}
+
+func TestParseDomains(t *testing.T) {
+	src := `identifiers
+  N = 3;
+  r = 1;
+domains
+  Procs = [1..N];
+  Levels = [0..1];
+events
+  loc l_up (r);
+  loc l_down (r);
+reachability = 1;
+network Pool (continuous)
+  aut Proc[Procs]
+    stt lvl[Levels] to (lvl[1]) l_up
+                    to (lvl[0]) l_down
+results
+  busy = st Proc[1] == lvl[1];
+`
+	m, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errs := Validate(m); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+
+	assertEqual(t, 2, len(m.Domains))
+	assertEqual(t, []int{1, 3}, []int{m.Domains[0].Low, m.Domains[0].High})
+
+	names := []string{}
+	for _, aut := range m.Network.Automata {
+		names = append(names, aut.Name)
+	}
+	assertEqual(t, []string{"Proc[1]", "Proc[2]", "Proc[3]"}, names)
+	assertEqual(t, []string{"lvl[0]", "lvl[1]"}, m.Network.Automata[0].States)
+
+	compiled, err := Compile(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recompiled, err := Parse(compiled)
+	if err != nil {
+		t.Fatalf("%s\n%s", err, compiled)
+	}
+	assertEqual(t, m.Domains, recompiled.Domains)
+	assertEqual(t, len(m.Network.Automata), len(recompiled.Network.Automata))
+	assertEqual(t, "Proc[1]", recompiled.Network.Automata[0].Name)
+}
+
+func TestParseFunctionalRates(t *testing.T) {
+	src := `identifiers
+  mu = 2;
+events
+  loc l_srv (mu * (st Server == Busy));
+  syn s_req (0.5);
+reachability = 1;
+network Queue (continuous)
+  aut Client
+    stt Idle to (Waiting) s_req(0.25)
+             to (Idle) s_req(0.75)
+  aut Server
+    stt Idle to (Busy) s_req
+    stt Busy to (Idle) l_srv
+results
+  busy = st Server == Busy;
+`
+	m, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errs := Validate(m); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+	assertEqual(t, "mu * (st Server == Busy)", m.Events[0].Rate)
+	assertEqual(t, "0.5", m.Events[1].Rate)
+	assertEqual(t, "0.25", m.Network.Automata[0].Transitions[0].Events[0].Probability)
+
+	compiled, err := Compile(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recompiled, err := Parse(compiled)
+	if err != nil {
+		t.Fatalf("%s\n%s", err, compiled)
+	}
+	for i, event := range m.Events {
+		assertEqual(t, event.Rate, recompiled.Events[i].Rate)
+	}
+	assertEqual(t, "0.75", recompiled.Network.Automata[0].Transitions[1].Events[0].Probability)
+}
+
+func TestParseResultOperators(t *testing.T) {
+	src := `identifiers
+  N = 3;
+  r = 1;
+events
+  loc l_work (r);
+reachability = nb [Clients] Busy <= N;
+network Farm (continuous)
+  aut Clients[N]
+    stt Idle to (Busy) l_work
+    stt Busy to (Idle) l_work
+results
+  busy = nb [Clients] Busy;
+  last = lst [Clients] Busy;
+  some = nb [Clients] Busy > 0 || st Clients[0] == Idle;
+  half = nb [Clients] Busy % 2 == 0 ? max(1, N - 1) : min(N, 2);
+`
+	m, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := Validate(m); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+
+	compiled, err := Compile(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recompiled, err := Parse(compiled)
+	if err != nil {
+		t.Fatalf("%s\n%s", err, compiled)
+	}
+	for i, res := range m.Results {
+		assertEqual(t, res.Expression, recompiled.Results[i].Expression)
+	}
+	assertEqual(t, m.Reachability.Expression, recompiled.Reachability.Expression)
+}
+
+func TestParseDiscreteNetwork(t *testing.T) {
+	src := `identifiers
+  p = 0.3;
+events
+  loc l_send (p);
+  loc l_ack (0.9);
+reachability = 1;
+network Protocol (discrete)
+  aut Sender
+    stt Ready to (Waiting) l_send
+    stt Waiting to (Ready) l_ack(0.5)
+                to (Waiting) l_ack(0.5)
+results
+  ready = st Sender == Ready;
+`
+	m, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := Validate(m); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+	assertEqual(t, true, m.Network.IsDiscrete())
+
+	compiled, err := Compile(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recompiled, err := Parse(compiled)
+	if err != nil {
+		t.Fatalf("%s\n%s", err, compiled)
+	}
+	assertEqual(t, "discrete", recompiled.Network.Type)
+}
+
+func TestParseFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-san")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "broken.san")
+	src := "events\n  loc l_work (r)\nreachability = 1;\n"
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseFile(path)
+	if err == nil || !strings.HasPrefix(err.Error(), "At "+path+":3:1: ") {
+		t.Errorf("Expected an error positioned on %s, got %v", path, err)
+	}
+
+	if _, err := ParseFile(filepath.Join(dir, "missing.san")); !os.IsNotExist(err) {
+		t.Errorf("Expected a missing file error, got %v", err)
+	}
+}
+
+func TestParseReader(t *testing.T) {
+	src := `events
+  loc l_work (1);
+reachability = 1;
+network Farm (continuous)
+  aut Worker
+    stt Idle to (Busy) l_work
+    stt Busy to (Idle) l_work
+`
+	m, err := ParseReader("farm.san", strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, "farm.san:2:15", m.Events[0].RateExpr.Pos().String())
+
+	_, err = ParseReader("farm.san", strings.NewReader(strings.Replace(src, "aut Worker", "aut Worker[0]", 1)))
+	if err == nil || !strings.HasPrefix(err.Error(), "At farm.san:5:14: ") {
+		t.Errorf("Expected an error positioned on farm.san, got %v", err)
+	}
+}
+
+func TestParseFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-san")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	paths := writeFiles(t, dir, map[string]string{
+		"events.san": `identifiers
+  r = 2;
+events
+  loc l_work (r);
+  loc l_watch (1);
+`,
+		"worker.san": `network Farm (continuous)
+  aut Worker
+    stt Idle to (Busy) l_work
+    stt Busy to (Idle) l_work
+results
+  busy = st Worker == Busy;
+`,
+		"monitor.san": `reachability = 1;
+network Farm (continuous)
+  aut Monitor
+    stt Watching to (Watching) l_watch
+results
+  watching = st Monitor == Watching;
+`,
+	}, "events.san", "worker.san", "monitor.san")
+
+	m, err := ParseFiles(paths...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := Validate(m); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+	names := []string{}
+	for _, aut := range m.Network.Automata {
+		names = append(names, aut.Name)
+	}
+	assertEqual(t, []string{"Worker", "Monitor"}, names)
+	assertEqual(t, 2, len(m.Results))
+	assertEqual(t, paths[0]+":4:15", m.Events[0].RateExpr.Pos().String())
+	assertEqual(t, paths[2]+":6:14", m.Results[1].Expr.Pos().String())
+}
+
+func TestParseFiles_Error(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-san")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	paths := writeFiles(t, dir, map[string]string{
+		"a.san": `events
+  loc l_work (1);
+reachability = 1;
+network Farm (continuous)
+  aut Worker
+    stt Idle to (Busy) l_work
+    stt Busy to (Idle) l_work
+`,
+		"b.san": `events
+  loc l_work (2);
+reachability = 1;
+network Farm (discrete)
+  aut Worker
+    stt Idle to (Idle) l_work
+`,
+	}, "a.san", "b.san")
+
+	_, err = ParseFiles(paths...)
+	list, ok := err.(parser.ErrorList)
+	if !ok {
+		t.Fatalf("Expected an ErrorList, got %v", err)
+	}
+	messages := []string{}
+	for _, e := range list {
+		messages = append(messages, e.Error())
+	}
+	assertEqual(t, []string{
+		fmt.Sprintf(`At %s:2:7: Event "l_work" already defined at %s:2:7`, paths[1], paths[0]),
+		fmt.Sprintf(`At %s:3:1: Reachability function already defined at %s:3:1`, paths[1], paths[0]),
+		fmt.Sprintf(`At %s:4:1: Network Farm (discrete) does not match network Farm (continuous) defined at %s:4:1`, paths[1], paths[0]),
+		fmt.Sprintf(`At %s:5:7: Automaton "Worker" already defined at %s:5:7`, paths[1], paths[0]),
+	}, messages)
+
+	if _, err := ParseFiles(); err == nil {
+		t.Error("Expected to error without files but did not")
+	}
+
+	paths = writeFiles(t, dir, map[string]string{"c.san": "events loc"}, "c.san")
+	if _, err := ParseFiles(append(paths, paths...)...); err == nil || !strings.Contains(err.Error(), "(and 1 more errors)") {
+		t.Errorf("Expected the syntax errors of every file, got %v", err)
+	}
+}
+
+// writeFiles writes files into dir and returns the paths of the given names
+func writeFiles(t *testing.T, dir string, files map[string]string, names ...string) []string {
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	paths := []string{}
+	for _, name := range names {
+		paths = append(paths, filepath.Join(dir, name))
+	}
+	return paths
+}
@@ -28,6 +28,8 @@ type Scanner struct {
 	tokStart int // token text start position
 	tokEnd   int // token text end  position
 
+	lastTok token.Type // type of the last token scanned, comments aside
+
 	// Error is called for each error encountered. If no Error
 	// function is set, the error is reported to os.Stderr.
 	Error func(pos token.Pos, msg string)
@@ -36,7 +38,8 @@ type Scanner struct {
 	ErrorCount int
 
 	// tokPos is the start position of most recently scanned token; set by
-	// Scan. The Filename field is always left untouched by the Scanner.  If
+	// Scan. The Filename field is set by NewFile and always left untouched by
+	// the Scanner afterwards. If
 	// an error is reported (via Error) and Position is invalid, the scanner is
 	// not inside a token.
 	tokPos token.Pos
@@ -59,6 +62,14 @@ func New(src []byte) *Scanner {
 	return s
 }
 
+// NewFile creates and initializes a new instance of Scanner using src as its
+// source content, the positions of its tokens and errors refer to filename.
+func NewFile(filename string, src []byte) *Scanner {
+	s := New(src)
+	s.tokPos.Filename = filename
+	return s
+}
+
 // next reads the next rune from the bufferred reader. Returns the rune(0) if
 // an error occurs (or io.EOF is returned).
 func (s *Scanner) next() rune {
@@ -116,6 +127,23 @@ func (s *Scanner) peek() rune {
 	return peek
 }
 
+// peekIs reports whether the next byte is ch. Unlike peek, it does not touch
+// the underlying buffer so the last read rune can still be unread.
+func (s *Scanner) peekIs(ch byte) bool {
+	return s.srcPos.Offset < len(s.src) && s.src[s.srcPos.Offset] == ch
+}
+
+// afterOperand reports whether the last token scanned ends an operand, in
+// which case a '-' that follows is a subtraction rather than the sign of a
+// number, as in `a -1` or `(a)-1`.
+func (s *Scanner) afterOperand() bool {
+	switch s.lastTok {
+	case token.IDENTIFIER, token.NUMBER, token.FLOAT, token.RPAREN, token.RBRACK:
+		return true
+	}
+	return false
+}
+
 // Scan scans the next token and returns the token.
 func (s *Scanner) Scan() token.Token {
 	ch := s.next()
@@ -151,6 +179,8 @@ func (s *Scanner) Scan() token.Token {
 		lit := s.scanIdentifier()
 		if isKeyword(lit) {
 			tok = keywordType(lit)
+		} else if isBuiltin(lit) {
+			tok = builtinType(lit)
 		}
 	case isDecimal(ch):
 		tok = s.scanNumber(ch)
@@ -170,8 +200,19 @@ func (s *Scanner) Scan() token.Token {
 			tok = token.LPAREN
 		case ')':
 			tok = token.RPAREN
+		case '[':
+			tok = token.LBRACK
+		case ']':
+			tok = token.RBRACK
 		case ';':
 			tok = token.SEMICOLON
+		case '.':
+			if s.peek() == '.' {
+				s.next()
+				tok = token.RANGE
+			} else {
+				s.err("illegal char " + string(ch))
+			}
 		case '+':
 			tok = token.SUM
 		case '=':
@@ -194,14 +235,41 @@ func (s *Scanner) Scan() token.Token {
 			} else {
 				s.err("illegal char " + string(ch))
 			}
+		case '|':
+			if s.peek() == '|' {
+				s.next()
+				tok = token.OR
+			} else {
+				s.err("illegal char " + string(ch))
+			}
+		case '<':
+			tok = token.LT
+			if s.peek() == '=' {
+				s.next()
+				tok = token.LEQ
+			}
+		case '>':
+			tok = token.GT
+			if s.peek() == '=' {
+				s.next()
+				tok = token.GEQ
+			}
 		case '*':
 			tok = token.MULT
+		case '%':
+			tok = token.MOD
+		case '?':
+			tok = token.QUESTION
+		case ':':
+			tok = token.COLON
+		case ',':
+			tok = token.COMMA
 		case '-':
-			if isDecimal(s.peek()) {
+			if isDecimal(s.peek()) && !s.afterOperand() {
 				ch := s.next()
 				tok = s.scanNumber(ch)
 			} else {
-				s.err("illegal char " + string(ch))
+				tok = token.SUB
 			}
 		default:
 			s.err("illegal " + string(ch))
@@ -218,6 +286,10 @@ func (s *Scanner) Scan() token.Token {
 	}
 	s.tokStart = s.tokEnd // ensure idempotency of tokenText() call
 
+	if tok != token.COMMENT {
+		s.lastTok = tok
+	}
+
 	return token.Token{
 		Type: tok,
 		Pos:  s.tokPos,
@@ -302,7 +374,7 @@ func (s *Scanner) scanNumber(ch rune) token.Type {
 			return token.FLOAT
 		}
 
-		if ch == '.' {
+		if ch == '.' && !s.peekIs('.') {
 			ch = s.scanFraction(ch)
 
 			if ch == 'e' || ch == 'E' {
@@ -329,7 +401,7 @@ func (s *Scanner) scanNumber(ch rune) token.Type {
 		return token.FLOAT
 	}
 
-	if ch == '.' {
+	if ch == '.' && !s.peekIs('.') {
 		ch = s.scanFraction(ch)
 		if ch == 'e' || ch == 'E' {
 			ch = s.next()
@@ -385,7 +457,7 @@ func (s *Scanner) scanExponent(ch rune) rune {
 func (s *Scanner) scanIdentifier() string {
 	offs := s.srcPos.Offset - s.lastCharLen
 	ch := s.next()
-	for isLetter(ch) || isDigit(ch) || ch == '-' || ch == '.' {
+	for isLetter(ch) || isDigit(ch) || ch == '.' && !s.peekIs('.') {
 		ch = s.next()
 	}
 
@@ -399,6 +471,7 @@ func (s *Scanner) scanIdentifier() string {
 // recentPosition returns the position of the character immediately after the
 // character or token returned by the last call to Scan.
 func (s *Scanner) recentPosition() (pos token.Pos) {
+	pos.Filename = s.tokPos.Filename
 	pos.Offset = s.srcPos.Offset - s.lastCharLen
 	switch {
 	case s.srcPos.Column > 0:
@@ -461,7 +534,7 @@ func isWhitespace(ch rune) bool {
 // isKeyword returns true if the identifier is a reserved keyword
 func isKeyword(lit string) bool {
 	switch lit {
-	case "identifiers", "events", "partial", "reachability", "network", "continuous", "aut", "stt", "to", "results", "st", "loc", "syn":
+	case "identifiers", "domains", "events", "partial", "reachability", "network", "continuous", "discrete", "aut", "stt", "to", "results", "st", "loc", "syn":
 		return true
 	}
 	return false
@@ -472,6 +545,8 @@ func keywordType(lit string) token.Type {
 	switch lit {
 	case "identifiers":
 		return token.IDENTIFIERS
+	case "domains":
+		return token.DOMAINS
 	case "events":
 		return token.EVENTS
 	case "partial":
@@ -482,6 +557,8 @@ func keywordType(lit string) token.Type {
 		return token.NETWORK
 	case "continuous":
 		return token.CONTINUOUS
+	case "discrete":
+		return token.DISCRETE
 	case "aut":
 		return token.AUT
 	case "st":
@@ -499,3 +576,27 @@ func keywordType(lit string) token.Type {
 	}
 	return token.ILLEGAL
 }
+
+// isBuiltin returns true if the identifier is a reserved built-in name
+func isBuiltin(lit string) bool {
+	switch lit {
+	case "min", "max", "nb", "lst":
+		return true
+	}
+	return false
+}
+
+// builtinType returns the token type for the given built-in name
+func builtinType(lit string) token.Type {
+	switch lit {
+	case "min":
+		return token.MIN
+	case "max":
+		return token.MAX
+	case "nb":
+		return token.NB
+	case "lst":
+		return token.LST
+	}
+	return token.ILLEGAL
+}
@@ -38,6 +38,9 @@ var tokenLists = map[string][]tokenPair{
 	"operator": []tokenPair{
 		{token.LPAREN, "("},
 		{token.RPAREN, ")"},
+		{token.LBRACK, "["},
+		{token.RBRACK, "]"},
+		{token.RANGE, ".."},
 		{token.ASSIGN, "="},
 		{token.SUM, "+"},
 		{token.MULT, "*"},
@@ -46,12 +49,21 @@ var tokenLists = map[string][]tokenPair{
 		{token.EQUAL, "=="},
 		{token.NEQUAL, "!="},
 		{token.NEG, "!"},
+		{token.SUB, "-"},
+		{token.MOD, "%"},
+		{token.OR, "||"},
+		{token.LT, "<"},
+		{token.GT, ">"},
+		{token.LEQ, "<="},
+		{token.GEQ, ">="},
+		{token.QUESTION, "?"},
+		{token.COLON, ":"},
+		{token.COMMA, ","},
 	},
 	"ident": []tokenPair{
 		{token.IDENTIFIER, "a"},
 		{token.IDENTIFIER, "a0"},
 		{token.IDENTIFIER, "foobar"},
-		{token.IDENTIFIER, "foo-bar"},
 		{token.IDENTIFIER, "abc123"},
 		{token.IDENTIFIER, "LGTM"},
 		{token.IDENTIFIER, "_"},
@@ -68,17 +80,25 @@ var tokenLists = map[string][]tokenPair{
 	},
 	"keyword": []tokenPair{
 		{token.IDENTIFIERS, "identifiers"},
+		{token.DOMAINS, "domains"},
 		{token.EVENTS, "events"},
 		{token.PARTIAL, "partial"},
 		{token.REACHABILITY, "reachability"},
 		{token.NETWORK, "network"},
 		{token.CONTINUOUS, "continuous"},
+		{token.DISCRETE, "discrete"},
 		{token.AUT, "aut"},
 		{token.ST, "st"},
 		{token.STT, "stt"},
 		{token.TO, "to"},
 		{token.RESULTS, "results"},
 	},
+	"builtin": []tokenPair{
+		{token.MIN, "min"},
+		{token.MAX, "max"},
+		{token.NB, "nb"},
+		{token.LST, "lst"},
+	},
 	"number": []tokenPair{
 		{token.NUMBER, "0"},
 		{token.NUMBER, "1"},
@@ -212,6 +232,7 @@ var orderedTokenLists = []string{
 	"operator",
 	"ident",
 	"keyword",
+	"builtin",
 	"number",
 	"float",
 }
@@ -228,7 +249,7 @@ func TestPosition(t *testing.T) {
 
 	s := New(buf.Bytes())
 
-	pos := token.Pos{4, 1, 5}
+	pos := token.Pos{Offset: 4, Line: 1, Column: 5}
 	s.Scan()
 	for _, listName := range orderedTokenLists {
 
@@ -247,6 +268,7 @@ func TestPosition(t *testing.T) {
 			}
 			pos.Offset += 4 + len(k.text) + 1     // 4 tabs + token bytes + newline
 			pos.Line += countNewlines(k.text) + 1 // each token is on a new line
+			s.lastTok = token.ILLEGAL             // each token stands on its own, see testTokenList
 			s.Scan()
 		}
 	}
@@ -256,6 +278,25 @@ func TestPosition(t *testing.T) {
 	}
 }
 
+func TestFilename(t *testing.T) {
+	s := NewFile("model.san", []byte("events\n  loc @"))
+	errPos := ""
+	s.Error = func(p token.Pos, m string) {
+		errPos = p.String()
+	}
+
+	positions := []string{}
+	for tok := s.Scan(); tok.Type != token.EOF; tok = s.Scan() {
+		positions = append(positions, tok.Pos.String())
+	}
+	if strings.Join(positions, " ") != "model.san:1:1 model.san:2:3 model.san:2:7" {
+		t.Errorf("Unexpected positions %v", positions)
+	}
+	if errPos != "model.san:2:7" {
+		t.Errorf("pos = %q, want %q", errPos, "model.san:2:7")
+	}
+}
+
 func TestNullChar(t *testing.T) {
 	s := New([]byte("\"\\0"))
 	s.Scan() // Used to panic
@@ -289,6 +330,46 @@ func TestKeywords(t *testing.T) {
 	testTokenList(t, tokenLists["keyword"])
 }
 
+func TestBuiltins(t *testing.T) {
+	testTokenList(t, tokenLists["builtin"])
+}
+
+func TestExpressionOperators(t *testing.T) {
+	var testData = []struct {
+		src    string
+		tokens []tokenPair
+	}{
+		{"a - 1", []tokenPair{{token.IDENTIFIER, "a"}, {token.SUB, "-"}, {token.NUMBER, "1"}}},
+		{"a -1", []tokenPair{{token.IDENTIFIER, "a"}, {token.SUB, "-"}, {token.NUMBER, "1"}}},
+		{"K-1", []tokenPair{{token.IDENTIFIER, "K"}, {token.SUB, "-"}, {token.NUMBER, "1"}}},
+		{"K-(N)", []tokenPair{{token.IDENTIFIER, "K"}, {token.SUB, "-"}, {token.LPAREN, "("}, {token.IDENTIFIER, "N"}, {token.RPAREN, ")"}}},
+		{"foo-bar-1", []tokenPair{{token.IDENTIFIER, "foo"}, {token.SUB, "-"}, {token.IDENTIFIER, "bar"}, {token.SUB, "-"}, {token.NUMBER, "1"}}},
+		{"2-1.5", []tokenPair{{token.NUMBER, "2"}, {token.SUB, "-"}, {token.FLOAT, "1.5"}}},
+		{"(a)-1", []tokenPair{{token.LPAREN, "("}, {token.IDENTIFIER, "a"}, {token.RPAREN, ")"}, {token.SUB, "-"}, {token.NUMBER, "1"}}},
+		{"q[1] /* one */ -1", []tokenPair{{token.IDENTIFIER, "q"}, {token.LBRACK, "["}, {token.NUMBER, "1"}, {token.RBRACK, "]"}, {token.COMMENT, "/* one */"}, {token.SUB, "-"}, {token.NUMBER, "1"}}},
+		{"2*-1", []tokenPair{{token.NUMBER, "2"}, {token.MULT, "*"}, {token.NUMBER, "-1"}}},
+		{"-(a)", []tokenPair{{token.SUB, "-"}, {token.LPAREN, "("}, {token.IDENTIFIER, "a"}, {token.RPAREN, ")"}}},
+		{"a||b", []tokenPair{{token.IDENTIFIER, "a"}, {token.OR, "||"}, {token.IDENTIFIER, "b"}}},
+		{"a<=b>=c<d>e", []tokenPair{{token.IDENTIFIER, "a"}, {token.LEQ, "<="}, {token.IDENTIFIER, "b"}, {token.GEQ, ">="}, {token.IDENTIFIER, "c"}, {token.LT, "<"}, {token.IDENTIFIER, "d"}, {token.GT, ">"}, {token.IDENTIFIER, "e"}}},
+		{"a?1:2", []tokenPair{{token.IDENTIFIER, "a"}, {token.QUESTION, "?"}, {token.NUMBER, "1"}, {token.COLON, ":"}, {token.NUMBER, "2"}}},
+		{"min(a,b%2)", []tokenPair{{token.MIN, "min"}, {token.LPAREN, "("}, {token.IDENTIFIER, "a"}, {token.COMMA, ","}, {token.IDENTIFIER, "b"}, {token.MOD, "%"}, {token.NUMBER, "2"}, {token.RPAREN, ")"}}},
+		{"nb [Clients] Busy", []tokenPair{{token.NB, "nb"}, {token.LBRACK, "["}, {token.IDENTIFIER, "Clients"}, {token.RBRACK, "]"}, {token.IDENTIFIER, "Busy"}}},
+	}
+
+	for _, d := range testData {
+		s := New([]byte(d.src))
+		for _, pair := range d.tokens {
+			tok := s.Scan()
+			if tok.Type != pair.tok || tok.Text != pair.text {
+				t.Errorf("want: %s %q got: %s for %q", pair.tok, pair.text, tok, d.src)
+			}
+		}
+		if tok := s.Scan(); tok.Type != token.EOF {
+			t.Errorf("want: EOF got: %s for %q", tok, d.src)
+		}
+	}
+}
+
 func TestWindowsLineEndings(t *testing.T) {
 	san := `// This should have Windows line endings
 identifiers
@@ -474,6 +555,31 @@ results
 
 }
 
+func TestRanges(t *testing.T) {
+	var testData = []struct {
+		src    string
+		tokens []tokenPair
+	}{
+		{"[0..3]", []tokenPair{{token.LBRACK, "["}, {token.NUMBER, "0"}, {token.RANGE, ".."}, {token.NUMBER, "3"}, {token.RBRACK, "]"}}},
+		{"[12..K]", []tokenPair{{token.LBRACK, "["}, {token.NUMBER, "12"}, {token.RANGE, ".."}, {token.IDENTIFIER, "K"}, {token.RBRACK, "]"}}},
+		{"[K..N]", []tokenPair{{token.LBRACK, "["}, {token.IDENTIFIER, "K"}, {token.RANGE, ".."}, {token.IDENTIFIER, "N"}, {token.RBRACK, "]"}}},
+		{"1.5..a.b", []tokenPair{{token.FLOAT, "1.5"}, {token.RANGE, ".."}, {token.IDENTIFIER, "a.b"}}},
+	}
+
+	for _, d := range testData {
+		s := New([]byte(d.src))
+		for _, pair := range d.tokens {
+			tok := s.Scan()
+			if tok.Type != pair.tok || tok.Text != pair.text {
+				t.Errorf("want: %s %q got: %s for %q", pair.tok, pair.text, tok, d.src)
+			}
+		}
+		if tok := s.Scan(); tok.Type != token.EOF {
+			t.Errorf("want: EOF got: %s for %q", tok, d.src)
+		}
+	}
+}
+
 func TestError(t *testing.T) {
 	testError(t, "\x80", "1:1", "illegal UTF-8 encoding", token.ILLEGAL)
 	testError(t, "\xff", "1:1", "illegal UTF-8 encoding", token.ILLEGAL)
@@ -482,6 +588,7 @@ func TestError(t *testing.T) {
 	testError(t, "abc\xff", "1:4", "illegal UTF-8 encoding", token.IDENTIFIER)
 
 	testError(t, `&`, "1:1", "illegal char &", token.ILLEGAL)
+	testError(t, `|`, "1:1", "illegal char |", token.ILLEGAL)
 
 	testError(t, `01238`, "1:6", "illegal octal number", token.NUMBER)
 	testError(t, `01238123`, "1:9", "illegal octal number", token.NUMBER)
@@ -521,14 +628,10 @@ func testError(t *testing.T, src, pos, msg string, tok token.Type) {
 }
 
 func testTokenList(t *testing.T, tokenList []tokenPair) {
-	// create artifical source code
-	buf := new(bytes.Buffer)
-	for _, ident := range tokenList {
-		fmt.Fprintf(buf, "%s\n", ident.text)
-	}
-
-	s := New(buf.Bytes())
+	// each token is scanned on its own given that a '-' right after an
+	// operand is a subtraction rather than the sign of a number
 	for _, ident := range tokenList {
+		s := New([]byte(ident.text + "\n"))
 		tok := s.Scan()
 		if tok.Type != ident.tok {
 			t.Errorf("tok = %q want %q for %q\n", tok, ident.tok, ident.text)
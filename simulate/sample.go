@@ -0,0 +1,266 @@
+package sansimulate
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	sanctmc "github.com/fgrehm/go-san/ctmc"
+	model "github.com/fgrehm/go-san/model"
+)
+
+// Sampler draws exact samples of the stationary distribution of a network
+// through coupling from the past. The network is uniformized and its steps
+// are driven by the same event selection for every state: an event is picked
+// with a probability proportional to its largest rate and it fires with the
+// probability given by its rate on each state, the automata being routed
+// along the way. Discrete networks pick one of the transitions leaving each
+// state instead. Chains only coalesce if that selection lets them meet, which
+// is not the case of events that swap states back and forth, for instance.
+//
+// Monotone samplers only follow the chains that start on the lowest and on
+// the highest global states, where every automaton is on its first and on its
+// last state respectively, which is only exact for models whose events
+// preserve that order. Both states must satisfy the reachability function.
+// Other samplers follow the chains of every reachable state.
+type Sampler struct {
+	*Simulator
+	Monotone   bool
+	MaxHorizon int // the largest number of steps into the past, defaults to 1 << 20
+
+	reachable *sanctmc.Subspace // the reachable states, enumerated by samplers that are not monotone
+	rates     []float64         // the largest rate of each event, see sanctmc.Network.RateBound
+	total     float64
+	past      [][2]float64 // the random numbers of each step into the past
+}
+
+// DefaultMaxHorizon is the default largest number of steps into the past
+const DefaultMaxHorizon = 1 << 20
+
+// NewSampler returns a sampler of the stationary distribution of the network
+// of a model seeded with seed. The largest rate of each event is bounded from
+// the automata it depends on, see sanctmc.Network.RateBound, so that the state
+// space does not need to be enumerated.
+func NewSampler(m *model.Model, seed int64) (*Sampler, error) {
+	sim, err := New(m, seed)
+	if err != nil {
+		return nil, err
+	}
+	s := &Sampler{
+		Simulator:  sim,
+		MaxHorizon: DefaultMaxHorizon,
+		rates:      make([]float64, len(sim.Network.Events())),
+	}
+
+	for i := range s.rates {
+		if s.rates[i], err = sim.Network.RateBound(i); err != nil {
+			return nil, err
+		}
+		s.total += s.rates[i]
+	}
+	if s.total == 0 {
+		return nil, fmt.Errorf("Network %q has no enabled events", m.Network.Name)
+	}
+	return s, nil
+}
+
+// Sample returns a global state drawn from the stationary distribution. It
+// also moves the simulator to that state.
+func (s *Sampler) Sample() (int, error) {
+	start, err := s.start()
+	if err != nil {
+		return 0, err
+	}
+
+	s.past = s.past[:0]
+	for horizon := 1; horizon <= s.MaxHorizon; horizon *= 2 {
+		for len(s.past) < horizon {
+			s.past = append(s.past, [2]float64{s.rng.Float64(), s.rng.Float64()})
+		}
+
+		states := map[int]bool{}
+		for _, state := range start {
+			states[state] = true
+		}
+		// runs from time -horizon up to 0, the random numbers of step -t
+		// being found at past[t-1]. Chains that coalesce before time 0 must
+		// still run up to it.
+		for t := horizon; t > 0; t-- {
+			next := map[int]bool{}
+			for state := range states {
+				to, err := s.update(state, s.past[t-1])
+				if err != nil {
+					return 0, err
+				}
+				next[to] = true
+			}
+			states = next
+		}
+
+		if len(states) == 1 {
+			for state := range states {
+				s.State = state
+				return state, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("Chains did not coalesce within %d steps", s.MaxHorizon)
+}
+
+// start returns the states the chains start on. The lowest and highest
+// states of monotone samplers must satisfy the reachability function, the
+// reachable states being enumerated for the other samplers.
+func (s *Sampler) start() ([]int, error) {
+	if !s.Monotone {
+		if s.reachable == nil {
+			reachable, err := s.Network.Reachable()
+			if err != nil {
+				return nil, err
+			}
+			s.reachable = reachable
+		}
+		return s.reachable.States, nil
+	}
+
+	local := make([]int, len(s.Network.Space.Automata))
+	low := s.Network.Space.Encode(local)
+	for i, states := range s.Network.Space.States {
+		local[i] = len(states) - 1
+	}
+	start := []int{low, s.Network.Space.Encode(local)}
+	for _, state := range start {
+		ok, err := s.Network.Holds(state)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("Monotone sampling needs the reachability function to hold on state %s", s.Network.Space.Name(state))
+		}
+	}
+	return start, nil
+}
+
+// update returns the state reached from a state on a step driven by the
+// given random numbers
+func (s *Sampler) update(state int, u [2]float64) (int, error) {
+	if s.Network.Discrete {
+		transitions, err := s.Network.Successors(state)
+		if err != nil {
+			return 0, err
+		}
+		return pickOrdered(state, transitions, u[0], 1), nil
+	}
+
+	x := u[0] * s.total
+	event := len(s.rates) - 1
+	for i, r := range s.rates {
+		if x < r {
+			event = i
+			break
+		}
+		x -= r
+	}
+	transitions, err := s.Network.Fire(state, event)
+	if err != nil {
+		return 0, err
+	}
+	return pickOrdered(state, transitions, u[1], s.rates[event]), nil
+}
+
+// pickOrdered returns the state selected by u, uniformly distributed over
+// [0, 1), out of the targets of the transitions and of staying on from, which
+// takes what is left of scale. Targets are ordered by global state, so that
+// chains that start on different states meet on the same targets as often as
+// possible.
+func pickOrdered(from int, transitions []sanctmc.Transition, u, scale float64) int {
+	rates := map[int]float64{from: scale}
+	for _, t := range transitions {
+		rates[t.To] += t.Rate
+		rates[from] -= t.Rate
+	}
+	targets := make([]int, 0, len(rates))
+	for to := range rates {
+		targets = append(targets, to)
+	}
+	sort.Ints(targets)
+
+	x := u * scale
+	for _, to := range targets {
+		if x < rates[to] {
+			return to
+		}
+		x -= rates[to]
+	}
+	return from
+}
+
+// Samples holds independent samples of the stationary distribution
+type Samples struct {
+	States    []int               // the sampled global states
+	Estimates map[string]Estimate // the estimations of the results of the model, keyed by label
+	Space     *sanctmc.StateSpace
+}
+
+// SampleOptions configures the sampling of the results of a model
+type SampleOptions struct {
+	Seed       int64
+	Samples    int     // the number of independent samples, at least 2
+	Monotone   bool    // see Sampler
+	Confidence float64 // the level of the confidence intervals, defaults to 0.95
+}
+
+// Sample draws independent samples of the stationary distribution of the
+// network of a model and estimates its results out of them
+func Sample(m *model.Model, opts SampleOptions) (*Samples, error) {
+	if opts.Samples < 2 {
+		return nil, fmt.Errorf("At least 2 samples are needed for confidence intervals, got %d", opts.Samples)
+	}
+	if opts.Confidence <= 0 {
+		opts.Confidence = DefaultConfidence
+	}
+	if opts.Confidence >= 1 {
+		return nil, fmt.Errorf("Confidence level must be under 1, got %v", opts.Confidence)
+	}
+	s, err := NewSampler(m, opts.Seed)
+	if err != nil {
+		return nil, err
+	}
+	s.Monotone = opts.Monotone
+
+	res := &Samples{States: []int{}, Estimates: map[string]Estimate{}, Space: s.Network.Space}
+	samples := [][]float64{}
+	for i := 0; i < opts.Samples; i++ {
+		state, err := s.Sample()
+		if err != nil {
+			return nil, err
+		}
+		values, err := s.Values(state)
+		if err != nil {
+			return nil, err
+		}
+		res.States = append(res.States, state)
+		samples = append(samples, values)
+	}
+
+	for i, r := range m.Results {
+		res.Estimates[r.Label] = estimate(samples, i, opts.Confidence)
+	}
+	return res, nil
+}
+
+// estimate returns the mean of the i-th value of a set of independent samples
+// along with its confidence interval
+func estimate(samples [][]float64, i int, confidence float64) Estimate {
+	n := float64(len(samples))
+	mean, variance := 0.0, 0.0
+	for _, s := range samples {
+		mean += s[i]
+	}
+	mean /= n
+	for _, s := range samples {
+		variance += (s[i] - mean) * (s[i] - mean)
+	}
+	variance /= n - 1
+	t := studentQuantile(1-(1-confidence)/2, n-1)
+	return Estimate{Mean: mean, HalfWidth: t * math.Sqrt(variance/n)}
+}
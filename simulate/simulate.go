@@ -0,0 +1,141 @@
+package sansimulate
+
+import (
+	"fmt"
+	"math"
+
+	model "github.com/fgrehm/go-san/model"
+)
+
+// Options configures the estimation of the results of a model
+type Options struct {
+	Seed         int64
+	Initial      map[string]string // the initial local states, defaults to the state picked by New
+	Warmup       float64           // the simulated time discarded before each estimation
+	Length       float64           // the simulated time of each batch or replication, must be positive
+	Batches      int               // the number of batches of a single long run, defaults to 30
+	Replications int               // the number of independent runs, used instead of batches when positive
+	Confidence   float64           // the level of the confidence intervals, defaults to 0.95
+}
+
+// The defaults of Options
+const (
+	DefaultBatches    = 30
+	DefaultConfidence = 0.95
+)
+
+// Estimate is the estimation of a result along with its confidence interval
+type Estimate struct {
+	Mean      float64
+	HalfWidth float64 // the half width of the confidence interval
+}
+
+// Low returns the lower bound of the confidence interval
+func (e Estimate) Low() float64 {
+	return e.Mean - e.HalfWidth
+}
+
+// High returns the upper bound of the confidence interval
+func (e Estimate) High() float64 {
+	return e.Mean + e.HalfWidth
+}
+
+func (e Estimate) String() string {
+	return fmt.Sprintf("%g ± %g", e.Mean, e.HalfWidth)
+}
+
+// Result holds the estimations of the results of a model
+type Result struct {
+	Estimates map[string]Estimate // the estimations keyed by result label
+	Time      float64             // the total simulated time, warm up included
+	Events    int                 // the total number of events fired
+}
+
+// Simulate estimates the results of a model as time averages over simulated
+// runs, either through the batch means of a single long run or through
+// independent replications
+func Simulate(m *model.Model, opts Options) (*Result, error) {
+	if opts.Length <= 0 {
+		return nil, fmt.Errorf("Simulation length must be positive, got %v", opts.Length)
+	}
+	if opts.Warmup < 0 {
+		return nil, fmt.Errorf("Warm up period must not be negative, got %v", opts.Warmup)
+	}
+	if opts.Batches <= 0 {
+		opts.Batches = DefaultBatches
+	}
+	if opts.Confidence <= 0 {
+		opts.Confidence = DefaultConfidence
+	}
+	if opts.Confidence >= 1 {
+		return nil, fmt.Errorf("Confidence level must be under 1, got %v", opts.Confidence)
+	}
+
+	runs := opts.Batches
+	if opts.Replications > 0 {
+		runs = opts.Replications
+	}
+	if runs < 2 {
+		return nil, fmt.Errorf("At least 2 batches or replications are needed for confidence intervals, got %d", runs)
+	}
+
+	sim, err := New(m, opts.Seed)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Initial != nil {
+		if err := sim.SetState(opts.Initial); err != nil {
+			return nil, err
+		}
+	}
+	initial := sim.State
+
+	samples := [][]float64{}
+	res := &Result{}
+	for r := 0; r < runs; r++ {
+		if r == 0 || opts.Replications > 0 {
+			// each replication gets its own stream of random numbers
+			sim.Reset(initial, opts.Seed+int64(r))
+			if opts.Warmup > 0 {
+				if _, err := sim.Run(opts.Warmup); err != nil {
+					return nil, err
+				}
+			}
+		}
+		averages, err := sim.Run(opts.Length)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, averages)
+		if opts.Replications > 0 || r == runs-1 {
+			res.Time += sim.Time
+			res.Events += sim.Events
+		}
+	}
+
+	res.Estimates = map[string]Estimate{}
+	for i, r := range m.Results {
+		res.Estimates[r.Label] = estimate(samples, i, opts.Confidence)
+	}
+	return res, nil
+}
+
+// studentQuantile approximates the quantile p of the Student's t
+// distribution with df degrees of freedom, exactly for 1 and 2 degrees of
+// freedom and through the Cornish-Fisher expansion around the normal quantile
+// otherwise
+func studentQuantile(p, df float64) float64 {
+	switch df {
+	case 1:
+		return math.Tan(math.Pi * (p - 0.5))
+	case 2:
+		return (2*p - 1) / math.Sqrt(2*p*(1-p))
+	}
+	z := math.Sqrt2 * math.Erfinv(2*p-1)
+	z2 := z * z
+	g1 := (z2 + 1) * z / 4
+	g2 := ((5*z2+16)*z2 + 3) * z / 96
+	g3 := (((3*z2+19)*z2+17)*z2 - 15) * z / 384
+	g4 := ((((79*z2+776)*z2+1482)*z2-1920)*z2 - 945) * z / 92160
+	return z + g1/df + g2/(df*df) + g3/(df*df*df) + g4/(df*df*df*df)
+}
@@ -0,0 +1,290 @@
+package sansimulate
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	san "github.com/fgrehm/go-san"
+	model "github.com/fgrehm/go-san/model"
+)
+
+// queue is an M/M/1/2 queue next to an independent switch, its stationary
+// distribution is the product of (9, 6, 4) / 19 and (On, Off) = (4, 1) / 5
+const queue = `
+identifiers
+  lambda = 2;
+  mu = 3;
+
+events
+  loc l_arr (lambda);
+  loc l_srv (mu);
+  loc l_off (1);
+  loc l_on (4);
+
+reachability = 1;
+
+network Queue (continuous)
+  aut Queue
+    stt Q0 to (Q1) l_arr
+    stt Q1 to (Q2) l_arr
+           to (Q0) l_srv
+    stt Q2 to (Q1) l_srv
+  aut Switch
+    stt On to (Off) l_off
+    stt Off to (On) l_on
+
+results
+  empty = st Queue == Q0;
+  on = st Switch == On;
+  throughput = mu * (st Queue != Q0);
+`
+
+var expected = map[string]float64{
+	"empty":      9. / 19,
+	"on":         4. / 5,
+	"throughput": 3 * 10. / 19,
+}
+
+func TestSimulate(t *testing.T) {
+	var testData = []Options{
+		{Seed: 1, Length: 500},
+		{Seed: 2, Length: 500, Warmup: 10, Replications: 20},
+		{Seed: 3, Length: 500, Initial: map[string]string{"Queue": "Q2", "Switch": "Off"}, Confidence: 0.99},
+	}
+
+	for _, opts := range testData {
+		res, err := Simulate(parse(t, queue), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Estimates) != len(expected) {
+			t.Errorf("want: %v got: %v", expected, res.Estimates)
+		}
+		for label, exp := range expected {
+			est := res.Estimates[label]
+			if est.HalfWidth <= 0 || math.Abs(est.Mean-exp) > 3*est.HalfWidth {
+				t.Errorf("want: %v got: %v for %s with %+v", exp, est, label, opts)
+			}
+		}
+		if res.Events == 0 || res.Time < opts.Length {
+			t.Errorf("Unexpected simulation totals %+v", res)
+		}
+	}
+}
+
+func TestSimulate_Seed(t *testing.T) {
+	a, err := Simulate(parse(t, queue), Options{Seed: 42, Length: 100, Batches: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Simulate(parse(t, queue), Options{Seed: 42, Length: 100, Batches: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Events != b.Events || a.Estimates["on"] != b.Estimates["on"] {
+		t.Errorf("Expected the same seed to produce the same results, got %+v and %+v", a, b)
+	}
+}
+
+func TestSimulate_Large(t *testing.T) {
+	// 2^30 global states, which are never enumerated
+	src := `
+events
+  loc l_off (1);
+  loc l_on (4);
+reachability = nb [Cell] Off == 0;
+network Cells (continuous)
+  aut Cell[30]
+    stt On to (Off) l_off
+    stt Off to (On) l_on
+results
+  on = nb [Cell] On;
+`
+	res, err := Simulate(parse(t, src), Options{Seed: 1, Length: 20, Warmup: 5, Replications: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if est := res.Estimates["on"]; math.Abs(est.Mean-24) > 3*est.HalfWidth {
+		t.Errorf("want: 24 got: %v", est)
+	}
+}
+
+func TestSimulate_Discrete(t *testing.T) {
+	src := `
+events
+  loc l_flip (0.25);
+reachability = 1;
+network Coin (discrete)
+  aut Coin
+    stt Heads to (Tails) l_flip
+    stt Tails to (Heads) l_flip
+results
+  heads = st Coin == Heads;
+`
+	res, err := Simulate(parse(t, src), Options{Seed: 1, Length: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if est := res.Estimates["heads"]; math.Abs(est.Mean-0.5) > 3*est.HalfWidth {
+		t.Errorf("want: 0.5 got: %v", est)
+	}
+	// each step takes a time unit, a quarter of them flip the coin
+	if res.Time != 30000 || math.Abs(float64(res.Events)/res.Time-0.25) > 0.01 {
+		t.Errorf("Unexpected simulation totals %+v", res)
+	}
+}
+
+func TestSimulator_Absorbing(t *testing.T) {
+	m := parse(t, strings.Replace(queue, "stt Off to (On) l_on", "stt Off to (Off) l_on", 1))
+	sim, err := New(m, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.SetState(map[string]string{"Queue": "Q0", "Switch": "Off"}); err != nil {
+		t.Fatal(err)
+	}
+	averages, err := sim.Run(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if averages[1] != 0 || sim.Time != 100 {
+		t.Errorf("Expected the switch to stay off for the whole run, got %v at %v", averages, sim.Time)
+	}
+
+	m = parse(t, `
+events
+  loc l_fail (1);
+reachability = 1;
+network Component (continuous)
+  aut Component
+    stt Up to (Down) l_fail
+    stt Down
+`)
+	if sim, err = New(m, 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range []bool{true, false} {
+		ok, err := sim.Step()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != exp {
+			t.Errorf("want: %v got: %v on %s", exp, ok, sim.Network.Space.Name(sim.State))
+		}
+	}
+}
+
+func TestSimulate_Error(t *testing.T) {
+	var testData = []struct {
+		opts     Options
+		expected string
+	}{
+		{Options{}, "Simulation length must be positive, got 0"},
+		{Options{Length: 1, Warmup: -1}, "Warm up period must not be negative, got -1"},
+		{Options{Length: 1, Confidence: 1}, "Confidence level must be under 1, got 1"},
+		{Options{Length: 1, Replications: 1}, "At least 2 batches or replications are needed for confidence intervals, got 1"},
+		{Options{Length: 1, Initial: map[string]string{"Queue": "Q0"}}, `Missing the state of automaton "Switch"`},
+	}
+
+	for _, d := range testData {
+		_, err := Simulate(parse(t, queue), d.opts)
+		if err == nil || !strings.Contains(err.Error(), d.expected) {
+			t.Errorf("want: %q got: %v", d.expected, err)
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	for _, monotone := range []bool{false, true} {
+		res, err := Sample(parse(t, queue), SampleOptions{Seed: 1, Samples: 2000, Monotone: monotone})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.States) != 2000 {
+			t.Fatalf("want: 2000 samples got: %d", len(res.States))
+		}
+		for label, exp := range expected {
+			est := res.Estimates[label]
+			if est.HalfWidth <= 0 || math.Abs(est.Mean-exp) > 3*est.HalfWidth {
+				t.Errorf("want: %v got: %v for %s with monotone = %v", exp, est, label, monotone)
+			}
+		}
+	}
+
+	src := `
+events
+  loc l_flip (0.25);
+reachability = 1;
+network Coin (discrete)
+  aut Coin
+    stt Heads to (Tails) l_flip
+    stt Tails to (Heads) l_flip
+results
+  heads = st Coin == Heads;
+`
+	res, err := Sample(parse(t, src), SampleOptions{Seed: 1, Samples: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if est := res.Estimates["heads"]; math.Abs(est.Mean-0.5) > 3*est.HalfWidth {
+		t.Errorf("want: 0.5 got: %v", est)
+	}
+}
+
+func TestSample_Error(t *testing.T) {
+	// states A and B never communicate, so their chains never coalesce
+	m := parse(t, `
+events
+  loc l_stay (1);
+reachability = 1;
+network Split (continuous)
+  aut Split
+    stt A to (A) l_stay
+    stt B to (B) l_stay
+`)
+	s, err := NewSampler(m, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.MaxHorizon = 64
+	if _, err := s.Sample(); err == nil || !strings.Contains(err.Error(), "Chains did not coalesce within 64 steps") {
+		t.Errorf("Expected chains not to coalesce, got %v", err)
+	}
+
+	m = parse(t, strings.Replace(queue, "reachability = 1;", "reachability = st Queue != Q2;", 1))
+	if _, err := Sample(m, SampleOptions{Samples: 2, Monotone: true}); err == nil || !strings.Contains(err.Error(), "Monotone sampling needs the reachability function to hold on state Queue=Q2 Switch=Off") {
+		t.Errorf("Expected an error for an unreachable highest state, got %v", err)
+	}
+
+	if _, err := Sample(parse(t, queue), SampleOptions{Samples: 1}); err == nil || !strings.Contains(err.Error(), "At least 2 samples are needed") {
+		t.Errorf("Expected an error for a single sample, got %v", err)
+	}
+}
+
+func TestStudentQuantile(t *testing.T) {
+	var testData = []struct {
+		p, df, expected float64
+	}{
+		{0.975, 1, 12.706},
+		{0.975, 2, 4.303},
+		{0.975, 5, 2.571},
+		{0.975, 10, 2.228},
+		{0.975, 29, 2.045},
+		{0.995, 19, 2.861},
+	}
+
+	for _, d := range testData {
+		if q := studentQuantile(d.p, d.df); math.Abs(q-d.expected) > 2e-3 {
+			t.Errorf("want: %v got: %v for p = %v and df = %v", d.expected, q, d.p, d.df)
+		}
+	}
+}
+
+func parse(t *testing.T, src string) *model.Model {
+	m, err := san.Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
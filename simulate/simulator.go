@@ -0,0 +1,193 @@
+package sansimulate
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	sanctmc "github.com/fgrehm/go-san/ctmc"
+	saneval "github.com/fgrehm/go-san/eval"
+	model "github.com/fgrehm/go-san/model"
+)
+
+// Simulator runs the network of a model as a discrete-event process. On each
+// step the enabled events are evaluated on the current global state, the
+// delay until the next firing is sampled from the exponential distribution of
+// their total rate and the event that fires, along with the routing of the
+// automata that take part on it, is picked proportionally to its rate.
+// Discrete networks advance one time unit per step instead, staying on the
+// same state with the probability that is left over.
+type Simulator struct {
+	Network *sanctmc.Network
+	State   int     // the current global state
+	Time    float64 // the current time
+	Events  int     // the number of events fired so far
+
+	rng     *rand.Rand
+	scope   *saneval.Scope
+	results model.Results
+	values  map[int][]float64 // the values of the results on each visited state
+}
+
+// New returns a simulator of the network of a model seeded with seed. The
+// simulation starts on a state where the reachability function holds, which
+// is found without enumerating the state space, see sanctmc.Network.Initial.
+func New(m *model.Model, seed int64) (*Simulator, error) {
+	n, err := sanctmc.Compile(m)
+	if err != nil {
+		return nil, err
+	}
+	initial, err := n.Initial()
+	if err != nil {
+		return nil, err
+	}
+	scope, err := saneval.Evaluate(m)
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range m.Results {
+		if res.Expr == nil {
+			return nil, fmt.Errorf("Unable to evaluate result %q: no expression available", res.Label)
+		}
+	}
+
+	return &Simulator{
+		Network: n,
+		State:   initial,
+		rng:     rand.New(rand.NewSource(seed)),
+		scope:   scope,
+		results: m.Results,
+		values:  map[int][]float64{},
+	}, nil
+}
+
+// SetState moves the simulation to the global state made up of the given
+// local states, one for each automaton
+func (s *Simulator) SetState(states map[string]string) error {
+	state, err := s.Network.Space.Lookup(states)
+	if err != nil {
+		return err
+	}
+	s.State = state
+	return nil
+}
+
+// Reset moves the simulation back to a global state at time zero and reseeds
+// its stream of random numbers
+func (s *Simulator) Reset(state int, seed int64) {
+	s.State = state
+	s.Time = 0
+	s.Events = 0
+	s.rng.Seed(seed)
+}
+
+// Step fires the next event, advancing the time up to it. It returns false
+// if the current state is absorbing.
+func (s *Simulator) Step() (bool, error) {
+	return s.step(math.Inf(1))
+}
+
+// step fires the next event unless it happens after until, in which case the
+// time advances up to until and the state is kept, which is sound given that
+// sojourn times are memoryless. Absorbing states also hold until then.
+func (s *Simulator) step(until float64) (bool, error) {
+	transitions, err := s.Network.Successors(s.State)
+	if err != nil {
+		return false, err
+	}
+	total := 0.0
+	for _, t := range transitions {
+		if t.Rate < 0 {
+			return false, fmt.Errorf("Event %q has a negative rate %v on state %s", t.Event, t.Rate, s.Network.Space.Name(s.State))
+		}
+		total += t.Rate
+	}
+	if total == 0 {
+		if !math.IsInf(until, 1) {
+			s.Time = until
+		}
+		return false, nil
+	}
+
+	delay, scale := 1.0, total
+	if s.Network.Discrete {
+		// the probability that is left over keeps the current state
+		scale = math.Max(total, 1)
+	} else {
+		delay = s.rng.ExpFloat64() / total
+	}
+	if s.Time+delay > until {
+		s.Time = until
+		return true, nil
+	}
+	if i, ok := Pick(transitions, s.rng.Float64()*scale); ok {
+		s.State = transitions[i].To
+		s.Events++
+	}
+	s.Time += delay
+	return true, nil
+}
+
+// Pick returns the transition selected by u, a value uniformly distributed
+// over [0, total), total being at least the sum of the rates of the
+// transitions. It returns false if u falls beyond the transitions.
+func Pick(transitions []sanctmc.Transition, u float64) (int, bool) {
+	for i, t := range transitions {
+		if u < t.Rate {
+			return i, true
+		}
+		u -= t.Rate
+	}
+	return 0, false
+}
+
+// Values returns the value of each result of the model on a global state
+func (s *Simulator) Values(state int) ([]float64, error) {
+	if values, ok := s.values[state]; ok {
+		return values, nil
+	}
+	local := make([]int, len(s.Network.Space.Automata))
+	s.Network.Space.Decode(state, local)
+	states := s.Network.Space.Global(local)
+
+	values := make([]float64, len(s.results))
+	for i, res := range s.results {
+		v, err := s.scope.Eval(res.Expr, states)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to evaluate result %q: %s", res.Label, err)
+		}
+		values[i] = v
+	}
+	if len(s.values) < maxCachedStates {
+		s.values[state] = values
+	}
+	return values, nil
+}
+
+// maxCachedStates bounds the number of states whose result values are kept
+const maxCachedStates = 1 << 20
+
+// Run simulates the network for the given amount of time and returns the time
+// average of each result over that period
+func (s *Simulator) Run(length float64) ([]float64, error) {
+	sums := make([]float64, len(s.results))
+	end := s.Time + length
+	for s.Time < end {
+		values, err := s.Values(s.State)
+		if err != nil {
+			return nil, err
+		}
+		start := s.Time
+		if _, err := s.step(end); err != nil {
+			return nil, err
+		}
+		for i, v := range values {
+			sums[i] += v * (s.Time - start)
+		}
+	}
+
+	for i := range sums {
+		sums[i] /= length
+	}
+	return sums, nil
+}
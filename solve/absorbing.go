@@ -0,0 +1,215 @@
+package sansolve
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	sanctmc "github.com/fgrehm/go-san/ctmc"
+	model "github.com/fgrehm/go-san/model"
+)
+
+// Absorption is the outcome of the absorbing analysis of a network
+type Absorption struct {
+	MeanTime   float64           // the mean time to absorption, in steps for discrete networks
+	Classes    []*AbsorbingClass // ordered by their first global state
+	Iterations int
+	Delta      float64
+	Converged  bool
+	Space      *sanctmc.StateSpace
+}
+
+// AbsorbingClass is a set of states that the network never leaves once it
+// gets to any of them, absorbing states are classes of a single state
+type AbsorbingClass struct {
+	States      []int    // the global states of the class
+	Names       []string // the names of the global states, as in `Client=Idle Server=Busy`
+	Probability float64  // the probability of being absorbed into the class
+}
+
+// Absorbing finds the absorbing classes among the reachable states of the
+// network of a model, which are the closed strongly connected components of
+// its transition graph, and computes the mean time to absorption as well as
+// the probability of absorption into each class starting from
+// Options.Initial, which defaults to the first reachable state. The expected
+// time spent on each transient state is found through Gauss-Seidel,
+// configured by Options.Tolerance and MaxIterations.
+func Absorbing(m *model.Model, opts Options) (*Absorption, error) {
+	opts = opts.withDefaults()
+	if opts.Descriptor {
+		return nil, fmt.Errorf("Absorbing analysis needs the transitions of the generator, which descriptors do not provide")
+	}
+	sys, err := newSystem(m, false)
+	if err != nil {
+		return nil, err
+	}
+	global := opts.Initial
+	if global == nil {
+		global = make([]float64, sys.space.Len())
+		global[sys.reachable.States[0]] = 1
+	}
+	x0, err := sys.initial(global)
+	if err != nil {
+		return nil, err
+	}
+	if err := normalize(x0); err != nil {
+		return nil, err
+	}
+
+	q := sys.q
+	comp, count := components(q)
+	closed := make([]bool, count)
+	for i := range closed {
+		closed[i] = true
+	}
+	for i := 0; i < q.N; i++ {
+		for k := q.RowPtr[i]; k < q.RowPtr[i+1]; k++ {
+			if j := q.ColIdx[k]; comp[j] != comp[i] && q.Val[k] != 0 {
+				closed[comp[i]] = false
+			}
+		}
+	}
+
+	res := &Absorption{Classes: []*AbsorbingClass{}, Converged: true, Space: sys.space}
+	classes := map[int]*AbsorbingClass{}
+	transient := []int{}
+	for i := 0; i < q.N; i++ {
+		if !closed[comp[i]] {
+			transient = append(transient, i)
+			continue
+		}
+		class, ok := classes[comp[i]]
+		if !ok {
+			class = &AbsorbingClass{States: []int{}, Names: []string{}}
+			classes[comp[i]] = class
+			res.Classes = append(res.Classes, class)
+		}
+		global := sys.reachable.States[i]
+		class.States = append(class.States, global)
+		class.Names = append(class.Names, sys.space.Name(global))
+		class.Probability += x0[i]
+	}
+	if len(transient) == 0 && len(res.Classes) == 1 {
+		return nil, fmt.Errorf("Network %q is irreducible, it has no absorbing states", m.Network.Name)
+	}
+
+	// tau * Q_TT = -x0_T gives the expected time spent on each transient state
+	tau, err := sojournTimes(q, transient, x0, opts, res)
+	if err != nil {
+		return nil, err
+	}
+	for t, i := range transient {
+		res.MeanTime += tau[t]
+		for k := q.RowPtr[i]; k < q.RowPtr[i+1]; k++ {
+			if j := q.ColIdx[k]; closed[comp[j]] {
+				classes[comp[j]].Probability += tau[t] * q.Val[k]
+			}
+		}
+	}
+	sort.Slice(res.Classes, func(i, j int) bool {
+		return res.Classes[i].States[0] < res.Classes[j].States[0]
+	})
+	return res, nil
+}
+
+// sojournTimes solves tau * Q_TT = -x0_T through Gauss-Seidel, Q_TT being the
+// generator restricted to the transient states
+func sojournTimes(q *sanctmc.Matrix, transient []int, x0 []float64, opts Options, res *Absorption) ([]float64, error) {
+	position := map[int]int{}
+	for t, i := range transient {
+		position[i] = t
+	}
+	cols := q.Transpose()
+	diag := q.Diagonal()
+	tau := make([]float64, len(transient))
+	for res.Iterations = 0; res.Iterations < opts.MaxIterations; {
+		res.Iterations++
+		res.Delta = 0
+		for t, j := range transient {
+			sum := -x0[j]
+			for k := cols.RowPtr[j]; k < cols.RowPtr[j+1]; k++ {
+				if i, ok := position[cols.ColIdx[k]]; ok && i != t {
+					sum -= tau[i] * cols.Val[k]
+				}
+			}
+			v := sum / diag[j]
+			res.Delta = math.Max(res.Delta, math.Abs(v-tau[t]))
+			tau[t] = v
+		}
+		if res.Delta < opts.Tolerance {
+			return tau, nil
+		}
+	}
+	res.Converged = false
+	return tau, nil
+}
+
+// components returns the strongly connected component of each state of the
+// transition graph of a generator along with the number of components,
+// through an iterative version of Tarjan's algorithm
+func components(q *sanctmc.Matrix) ([]int, int) {
+	n := q.N
+	index := make([]int, n)
+	low := make([]int, n)
+	comp := make([]int, n)
+	onStack := make([]bool, n)
+	for i := range index {
+		index[i] = -1
+	}
+	stack := []int{}
+	count, next := 0, 0
+
+	type frame struct{ v, k int }
+	for root := 0; root < n; root++ {
+		if index[root] >= 0 {
+			continue
+		}
+		calls := []frame{{root, q.RowPtr[root]}}
+		index[root], low[root] = next, next
+		next++
+		stack = append(stack, root)
+		onStack[root] = true
+
+		for len(calls) > 0 {
+			f := &calls[len(calls)-1]
+			if f.k < q.RowPtr[f.v+1] {
+				w := q.ColIdx[f.k]
+				f.k++
+				if w == f.v || q.Val[f.k-1] == 0 {
+					continue
+				}
+				if index[w] < 0 {
+					index[w], low[w] = next, next
+					next++
+					stack = append(stack, w)
+					onStack[w] = true
+					calls = append(calls, frame{w, q.RowPtr[w]})
+				} else if onStack[w] && index[w] < low[f.v] {
+					low[f.v] = index[w]
+				}
+				continue
+			}
+
+			v := f.v
+			calls = calls[:len(calls)-1]
+			if len(calls) > 0 {
+				if u := calls[len(calls)-1].v; low[v] < low[u] {
+					low[u] = low[v]
+				}
+			}
+			if low[v] == index[v] {
+				for {
+					w := stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+					onStack[w] = false
+					comp[w] = count
+					if w == v {
+						break
+					}
+				}
+				count++
+			}
+		}
+	}
+	return comp, count
+}
@@ -0,0 +1,328 @@
+package sansolve
+
+import (
+	"math"
+)
+
+// krylov builds orthonormal bases of the Krylov subspaces of the
+// preconditioned map v -> M^-1(v) * Q through the Arnoldi process
+type krylov struct {
+	op  Operator
+	pre Preconditioner
+	res *Result
+
+	v [][]float64 // the basis vectors
+	h [][]float64 // the Hessenberg matrix, h[i][j] for i <= j+1
+	z []float64   // the preconditioned vector
+}
+
+func newKrylov(op Operator, pre Preconditioner, m int, res *Result) *krylov {
+	if pre == nil {
+		pre = identity{}
+	}
+	k := &krylov{op: op, pre: pre, res: res, z: make([]float64, op.Size())}
+	for i := 0; i <= m; i++ {
+		k.v = append(k.v, make([]float64, op.Size()))
+		k.h = append(k.h, make([]float64, m))
+	}
+	return k
+}
+
+// step extends the basis with its (j+1)-th vector, it returns false on a
+// breakdown, which happens when the subspace is invariant
+func (k *krylov) step(j int) (bool, error) {
+	k.pre.Apply(k.v[j], k.z)
+	w := k.v[j+1]
+	if err := k.op.VecMul(k.z, w); err != nil {
+		return false, err
+	}
+	k.res.Iterations++
+
+	// modified Gram-Schmidt
+	for i := 0; i <= j; i++ {
+		k.h[i][j] = dot(w, k.v[i])
+		axpy(-k.h[i][j], k.v[i], w)
+	}
+	k.h[j+1][j] = norm(w)
+	if k.h[j+1][j] <= breakdownTolerance {
+		return false, nil
+	}
+	scal(1/k.h[j+1][j], w)
+	return true, nil
+}
+
+// combine stores on x the preconditioned combination M^-1(V * y)
+func (k *krylov) combine(y, x []float64) {
+	for i := range k.z {
+		k.z[i] = 0
+	}
+	for i, c := range y {
+		axpy(c, k.v[i], k.z)
+	}
+	k.pre.Apply(k.z, x)
+}
+
+// breakdownTolerance is the norm under which a new basis vector is
+// considered to vanish
+const breakdownTolerance = 1e-14
+
+// DefaultRestart is the default dimension of the Krylov subspaces
+const DefaultRestart = 30
+
+// GMRES computes the stationary distribution through the restarted GMRES
+// method, which minimizes the residual of pi * Q = 0 over Krylov subspaces of
+// dimension Options.Restart. The preconditioner is optional and it is applied
+// on the right. GMRES converges once the residual gets under the tolerance.
+func GMRES(op Operator, pre Preconditioner, opts Options) (*Result, error) {
+	opts = opts.withDefaults()
+	x, err := initial(op.Size(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	m := restart(op, opts)
+	res := &Result{}
+	k := newKrylov(op, pre, m, res)
+	r := make([]float64, len(x))
+	dx := make([]float64, len(x))
+	g := make([]float64, m+1)
+	cs, sn := make([]float64, m), make([]float64, m)
+
+	for res.Iterations < opts.MaxIterations {
+		if err := op.VecMul(x, r); err != nil {
+			return nil, err
+		}
+		if res.Residual = maxAbs(r); res.Residual < opts.Tolerance {
+			res.Converged = true
+			break
+		}
+
+		// the residual of the correction dx is -x * Q - M^-1(dx) * Q
+		scal(-1, r)
+		beta := norm(r)
+		copy(k.v[0], r)
+		scal(1/beta, k.v[0])
+		for i := range g {
+			g[i] = 0
+		}
+		g[0] = beta
+
+		n := 0
+		for j := 0; j < m && res.Iterations < opts.MaxIterations; j++ {
+			ok, err := k.step(j)
+			if err != nil {
+				return nil, err
+			}
+			// reduces the Hessenberg matrix to upper triangular through
+			// Givens rotations, g is the right hand side of the least squares
+			h := k.h
+			for i := 0; i < j; i++ {
+				h[i][j], h[i+1][j] = cs[i]*h[i][j]+sn[i]*h[i+1][j], -sn[i]*h[i][j]+cs[i]*h[i+1][j]
+			}
+			d := math.Hypot(h[j][j], h[j+1][j])
+			cs[j], sn[j] = h[j][j]/d, h[j+1][j]/d
+			h[j][j], h[j+1][j] = d, 0
+			g[j], g[j+1] = cs[j]*g[j], -sn[j]*g[j]
+			n = j + 1
+			if !ok || math.Abs(g[j+1]) < opts.Tolerance {
+				break
+			}
+		}
+
+		y := make([]float64, n)
+		for i := n - 1; i >= 0; i-- {
+			y[i] = g[i]
+			for l := i + 1; l < n; l++ {
+				y[i] -= k.h[i][l] * y[l]
+			}
+			y[i] /= k.h[i][i]
+		}
+		k.combine(y, dx)
+		prev := append([]float64{}, x...)
+		axpy(1, dx, x)
+		if err := normalize(x); err != nil {
+			return nil, err
+		}
+		res.Delta = maxDiff(x, prev)
+	}
+	return finish(op, x, res)
+}
+
+// Arnoldi computes the stationary distribution as the eigenvector of the
+// eigenvalue 0 of Q, which is approximated on Krylov subspaces of dimension
+// Options.Restart by the null vector of their Hessenberg matrix. The
+// preconditioner is optional and it is applied on the right. Arnoldi converges
+// once the residual gets under the tolerance.
+func Arnoldi(op Operator, pre Preconditioner, opts Options) (*Result, error) {
+	opts = opts.withDefaults()
+	x, err := initial(op.Size(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	m := restart(op, opts)
+	res := &Result{}
+	k := newKrylov(op, pre, m, res)
+	r := make([]float64, len(x))
+	// the starting vector of the basis is the one the preconditioner maps
+	// into the current approximation, which is only known for the first one
+	start := append([]float64{}, x...)
+
+	for res.Iterations < opts.MaxIterations {
+		if err := op.VecMul(x, r); err != nil {
+			return nil, err
+		}
+		if res.Residual = maxAbs(r); res.Residual < opts.Tolerance {
+			res.Converged = true
+			break
+		}
+
+		copy(k.v[0], start)
+		scal(1/norm(start), k.v[0])
+		n := 0
+		for j := 0; j < m && res.Iterations < opts.MaxIterations; j++ {
+			ok, err := k.step(j)
+			if err != nil {
+				return nil, err
+			}
+			n = j + 1
+			if !ok {
+				break
+			}
+		}
+
+		y := nullVector(k.h, n)
+		prev := append([]float64{}, x...)
+		for i := range start {
+			start[i] = 0
+		}
+		for i, c := range y {
+			axpy(c, k.v[i], start)
+		}
+		k.pre.Apply(start, x)
+		if err := normalize(x); err != nil {
+			return nil, err
+		}
+		res.Delta = maxDiff(x, prev)
+	}
+	return finish(op, x, res)
+}
+
+// nullVector approximates the null vector of the leading n x n block of an
+// upper Hessenberg matrix through inverse iteration
+func nullVector(h [][]float64, n int) []float64 {
+	a := make([][]float64, n)
+	for i := range a {
+		a[i] = append([]float64{}, h[i][:n]...)
+	}
+	perm := luDecompose(a)
+
+	y := make([]float64, n)
+	for i := range y {
+		y[i] = 1
+	}
+	for iter := 0; iter < 3; iter++ {
+		luSolve(a, perm, y)
+		scal(1/norm(y), y)
+	}
+	return y
+}
+
+// luDecompose factors a dense matrix in place through Gaussian elimination
+// with partial pivoting, vanishing pivots are replaced by a tiny value given
+// that the matrix is meant to be singular
+func luDecompose(a [][]float64) []int {
+	n := len(a)
+	scale := 0.0
+	for i := range a {
+		for _, v := range a[i] {
+			scale = math.Max(scale, math.Abs(v))
+		}
+	}
+	tiny := math.Max(scale, 1) * 1e-14
+
+	perm := make([]int, n)
+	for k := 0; k < n; k++ {
+		p := k
+		for i := k + 1; i < n; i++ {
+			if math.Abs(a[i][k]) > math.Abs(a[p][k]) {
+				p = i
+			}
+		}
+		a[k], a[p] = a[p], a[k]
+		perm[k] = p
+		if math.Abs(a[k][k]) < tiny {
+			a[k][k] = tiny
+		}
+		for i := k + 1; i < n; i++ {
+			a[i][k] /= a[k][k]
+			for j := k + 1; j < n; j++ {
+				a[i][j] -= a[i][k] * a[k][j]
+			}
+		}
+	}
+	return perm
+}
+
+// luSolve solves a * x = b in place on b out of the factors of luDecompose
+func luSolve(a [][]float64, perm []int, b []float64) {
+	n := len(a)
+	for k, p := range perm {
+		b[k], b[p] = b[p], b[k]
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j < i; j++ {
+			b[i] -= a[i][j] * b[j]
+		}
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := i + 1; j < n; j++ {
+			b[i] -= a[i][j] * b[j]
+		}
+		b[i] /= a[i][i]
+	}
+}
+
+func restart(op Operator, opts Options) int {
+	m := opts.Restart
+	if m <= 0 {
+		m = DefaultRestart
+	}
+	if m > op.Size() {
+		m = op.Size()
+	}
+	return m
+}
+
+func dot(x, y []float64) float64 {
+	sum := 0.0
+	for i := range x {
+		sum += x[i] * y[i]
+	}
+	return sum
+}
+
+func norm(x []float64) float64 {
+	return math.Sqrt(dot(x, x))
+}
+
+// axpy computes y = a * x + y
+func axpy(a float64, x, y []float64) {
+	for i := range x {
+		y[i] += a * x[i]
+	}
+}
+
+func scal(a float64, x []float64) {
+	for i := range x {
+		x[i] *= a
+	}
+}
+
+func maxAbs(x []float64) float64 {
+	max := 0.0
+	for _, v := range x {
+		max = math.Max(max, math.Abs(v))
+	}
+	return max
+}
@@ -0,0 +1,144 @@
+package sansolve
+
+import (
+	"fmt"
+	"math"
+
+	sanctmc "github.com/fgrehm/go-san/ctmc"
+)
+
+// Preconditioner approximates the inverse of a generator
+type Preconditioner interface {
+	// Apply computes y ≈ x * Q^-1
+	Apply(x, y []float64)
+}
+
+// Preconditioning is a kind of Preconditioner
+type Preconditioning string
+
+// The supported preconditioners
+const (
+	PreconditionNone     Preconditioning = ""
+	PreconditionDiagonal Preconditioning = "diagonal"
+	PreconditionILU0     Preconditioning = "ilu0"
+)
+
+// diagonal scales each element by the inverse of the diagonal of Q
+type diagonal []float64
+
+// NewDiagonalPreconditioner returns the Jacobi preconditioner of a generator,
+// which only needs its diagonal and so works with descriptors as well
+func NewDiagonalPreconditioner(op Operator) (Preconditioner, error) {
+	diag, err := op.Diagonal()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDiagonal(diag); err != nil {
+		return nil, err
+	}
+	return diagonal(diag), nil
+}
+
+// newDiagonal returns the Jacobi preconditioner of a diagonal, states whose
+// diagonal element is zero are left unscaled
+func newDiagonal(diag []float64) Preconditioner {
+	d := make(diagonal, len(diag))
+	for i, v := range diag {
+		d[i] = v
+		if v == 0 {
+			d[i] = 1
+		}
+	}
+	return d
+}
+
+func (d diagonal) Apply(x, y []float64) {
+	for i := range x {
+		y[i] = x[i] / d[i]
+	}
+}
+
+// ilu0 holds the incomplete LU factorization of a generator that keeps its
+// sparsity pattern, the unit lower triangle L and the upper triangle U share
+// the storage of Q
+type ilu0 struct {
+	lu   *sanctmc.Matrix
+	diag []int // the position of the diagonal element of each row
+}
+
+// pivotTolerance is the relative size under which pivots are considered to
+// vanish
+const pivotTolerance = 1e-12
+
+// NewILU0Preconditioner returns the ILU(0) preconditioner of a flat
+// generator. Pivots that vanish, as the last one of a generator usually does
+// given that generators are singular, are replaced by the diagonal element of
+// the generator.
+func NewILU0Preconditioner(q *sanctmc.Matrix) (Preconditioner, error) {
+	lu := &sanctmc.Matrix{
+		N:      q.N,
+		RowPtr: q.RowPtr,
+		ColIdx: q.ColIdx,
+		Val:    append([]float64{}, q.Val...),
+	}
+	p := &ilu0{lu: lu, diag: make([]int, q.N)}
+	for i := 0; i < q.N; i++ {
+		p.diag[i] = -1
+		for k := lu.RowPtr[i]; k < lu.RowPtr[i+1]; k++ {
+			if lu.ColIdx[k] == i {
+				p.diag[i] = k
+			}
+		}
+		if p.diag[i] < 0 {
+			return nil, fmt.Errorf("Global state %d has no outgoing transitions", i)
+		}
+	}
+
+	pos := map[int]int{}
+	for i := 0; i < q.N; i++ {
+		for k := lu.RowPtr[i]; k < lu.RowPtr[i+1]; k++ {
+			pos[lu.ColIdx[k]] = k
+		}
+		for k := lu.RowPtr[i]; k < p.diag[i]; k++ {
+			row := lu.ColIdx[k]
+			lu.Val[k] /= lu.Val[p.diag[row]]
+			for l := p.diag[row] + 1; l < lu.RowPtr[row+1]; l++ {
+				if at, ok := pos[lu.ColIdx[l]]; ok {
+					lu.Val[at] -= lu.Val[k] * lu.Val[l]
+				}
+			}
+		}
+		if d := p.diag[i]; math.Abs(lu.Val[d]) <= pivotTolerance*math.Abs(q.Val[d]) {
+			lu.Val[d] = q.Val[d]
+		}
+		for k := lu.RowPtr[i]; k < lu.RowPtr[i+1]; k++ {
+			delete(pos, lu.ColIdx[k])
+		}
+	}
+	return p, nil
+}
+
+// Apply solves w * U = x and then y * L = w
+func (p *ilu0) Apply(x, y []float64) {
+	lu := p.lu
+	w := append([]float64{}, x...)
+	for i := 0; i < lu.N; i++ {
+		w[i] /= lu.Val[p.diag[i]]
+		for k := p.diag[i] + 1; k < lu.RowPtr[i+1]; k++ {
+			w[lu.ColIdx[k]] -= w[i] * lu.Val[k]
+		}
+	}
+	copy(y, w)
+	for i := lu.N - 1; i >= 0; i-- {
+		for k := lu.RowPtr[i]; k < p.diag[i]; k++ {
+			y[lu.ColIdx[k]] -= y[i] * lu.Val[k]
+		}
+	}
+}
+
+// identity is used when no preconditioner is given
+type identity struct{}
+
+func (identity) Apply(x, y []float64) {
+	copy(y, x)
+}
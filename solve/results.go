@@ -0,0 +1,49 @@
+package sansolve
+
+import (
+	"fmt"
+
+	saneval "github.com/fgrehm/go-san/eval"
+	model "github.com/fgrehm/go-san/model"
+)
+
+// EvaluateResults evaluates each expression of the `results` block of a
+// model as its expected value over the distribution of a Result, as in
+// `sum(pi(s) * f(s))`. Boolean expressions such as `st Client == Idle` thus
+// evaluate to probabilities and rates multiplied by them to throughputs. The
+// values are keyed by result label.
+func EvaluateResults(m *model.Model, r *Result) (map[string]float64, error) {
+	if r.Space == nil {
+		return nil, fmt.Errorf("Unable to evaluate results without a state space")
+	}
+	scope, err := saneval.Evaluate(m)
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range m.Results {
+		if res.Expr == nil {
+			return nil, fmt.Errorf("Unable to evaluate result %q: no expression available", res.Label)
+		}
+	}
+
+	values := map[string]float64{}
+	for _, res := range m.Results {
+		values[res.Label] = 0
+	}
+	local := make([]int, len(r.Space.Automata))
+	states := r.Space.Global(local)
+	for s, p := range r.Probabilities {
+		if p == 0 {
+			continue
+		}
+		r.Space.Decode(s, local)
+		for _, res := range m.Results {
+			v, err := scope.Eval(res.Expr, states)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to evaluate result %q: %s", res.Label, err)
+			}
+			values[res.Label] += p * v
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,197 @@
+package sansolve
+
+import (
+	"fmt"
+
+	sanctmc "github.com/fgrehm/go-san/ctmc"
+	model "github.com/fgrehm/go-san/model"
+)
+
+// Operator is the generator of a network, either as a flat sparse matrix or
+// as a descriptor. Discrete networks are represented by P - I, so that their
+// stationary distribution is also the solution of pi * Q = 0.
+type Operator interface {
+	Size() int                    // the number of global states
+	VecMul(x, y []float64) error  // the row vector by generator product y = x * Q
+	Diagonal() ([]float64, error) // the diagonal elements of the generator
+}
+
+// Method is a numerical method for the stationary distribution of a network
+type Method string
+
+// The supported methods
+const (
+	MethodPower       Method = "power"
+	MethodJacobi      Method = "jacobi"
+	MethodGaussSeidel Method = "gauss-seidel"
+	MethodGMRES       Method = "gmres"
+	MethodArnoldi     Method = "arnoldi"
+)
+
+// Options configures the solvers, zero values fall back to the defaults
+type Options struct {
+	Method Method // defaults to MethodPower
+	// Tolerance is the largest change between iterations accepted as
+	// converged by the power, Jacobi and Gauss-Seidel methods, while Krylov
+	// methods converge once every element of pi * Q is under it. Defaults to
+	// 1e-10.
+	Tolerance     float64
+	MaxIterations int       // the largest number of products by the generator, defaults to 10000
+	Relaxation    float64   // the relaxation factor of Jacobi and Gauss-Seidel, defaults to 1
+	Initial       []float64 // the initial vector, defaults to the uniform distribution
+	Descriptor    bool      // solves over the descriptor instead of the flat generator
+
+	Restart        int             // the dimension of the Krylov subspaces of GMRES and Arnoldi, defaults to 30
+	Preconditioner Preconditioning // the preconditioner of GMRES and Arnoldi, if any
+}
+
+// The defaults of Options
+const (
+	DefaultTolerance     = 1e-10
+	DefaultMaxIterations = 10000
+)
+
+func (o Options) withDefaults() Options {
+	if o.Method == "" {
+		o.Method = MethodPower
+	}
+	if o.Tolerance <= 0 {
+		o.Tolerance = DefaultTolerance
+	}
+	if o.MaxIterations <= 0 {
+		o.MaxIterations = DefaultMaxIterations
+	}
+	if o.Relaxation <= 0 {
+		o.Relaxation = 1
+	}
+	return o
+}
+
+// Result is the outcome of a solver
+type Result struct {
+	Probabilities []float64 // the probability of each global state
+	Iterations    int       // the number of iterations performed
+	Delta         float64   // the largest change on the last iteration
+	Residual      float64   // the largest element of pi * Q
+	Converged     bool      // false if MaxIterations was reached first
+	Time          float64   // the time point of transient distributions
+
+	// Space numbers the global states, it is only set by Solve
+	Space *sanctmc.StateSpace
+}
+
+// Decode returns the local state of each automaton on a global state
+func (r *Result) Decode(index int) map[string]string {
+	local := make([]int, len(r.Space.Automata))
+	r.Space.Decode(index, local)
+	states := map[string]string{}
+	for i, l := range local {
+		states[r.Space.Automata[i]] = r.Space.States[i][l]
+	}
+	return states
+}
+
+// Probability returns the probability of the global state made up of the
+// given local states, automata that are left out may be on any state
+func (r *Result) Probability(states map[string]string) (float64, error) {
+	local := make([]int, len(r.Space.Automata))
+	for name, state := range states {
+		i, ok := r.Space.AutomatonIndex(name)
+		if !ok {
+			return 0, fmt.Errorf("Unknown automaton %q", name)
+		}
+		if _, ok := r.Space.StateIndex(i, state); !ok {
+			return 0, fmt.Errorf("Unknown state %q of automaton %q", state, name)
+		}
+	}
+
+	p := 0.0
+	for s, v := range r.Probabilities {
+		r.Space.Decode(s, local)
+		matches := true
+		for name, state := range states {
+			i, _ := r.Space.AutomatonIndex(name)
+			if r.Space.States[i][local[i]] != state {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			p += v
+		}
+	}
+	return p, nil
+}
+
+// Solve computes the stationary distribution of the network of a model over
+// its reachable states, see sanctmc.Network.Reachable. Options.Initial, if
+// given, is indexed by global state as are the returned probabilities.
+func Solve(m *model.Model, opts Options) (*Result, error) {
+	opts = opts.withDefaults()
+	if opts.Descriptor && opts.Method == MethodGaussSeidel {
+		return nil, fmt.Errorf("Gauss-Seidel needs the columns of the generator, which descriptors do not provide")
+	}
+	sys, err := newSystem(m, opts.Descriptor)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Initial, err = sys.initial(opts.Initial); err != nil {
+		return nil, err
+	}
+
+	var res *Result
+	switch opts.Method {
+	case MethodPower:
+		res, err = Power(sys.op, opts)
+	case MethodJacobi:
+		var diag []float64
+		if diag, err = sys.diagonal(); err != nil {
+			return nil, err
+		}
+		res, err = jacobi(sys.op, diag, opts)
+	case MethodGaussSeidel:
+		if _, err = sys.diagonal(); err != nil {
+			return nil, err
+		}
+		res, err = GaussSeidel(sys.q, opts)
+	case MethodGMRES, MethodArnoldi:
+		var pre Preconditioner
+		if pre, err = sys.preconditioner(opts.Preconditioner); err != nil {
+			return nil, err
+		}
+		if opts.Method == MethodGMRES {
+			res, err = GMRES(sys.op, pre, opts)
+		} else {
+			res, err = Arnoldi(sys.op, pre, opts)
+		}
+	default:
+		return nil, fmt.Errorf("Unknown method %q", opts.Method)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sys.finish(res)
+	return res, nil
+}
+
+// NewMatrixOperator returns the Operator of a flat generator
+func NewMatrixOperator(q *sanctmc.Matrix) Operator {
+	return &matrixOperator{q}
+}
+
+type matrixOperator struct {
+	q *sanctmc.Matrix
+}
+
+func (m *matrixOperator) Size() int {
+	return m.q.Size()
+}
+
+func (m *matrixOperator) VecMul(x, y []float64) error {
+	m.q.VecMul(x, y)
+	return nil
+}
+
+func (m *matrixOperator) Diagonal() ([]float64, error) {
+	return m.q.Diagonal(), nil
+}
@@ -0,0 +1,490 @@
+package sansolve
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	san "github.com/fgrehm/go-san"
+	model "github.com/fgrehm/go-san/model"
+)
+
+// queue is an M/M/1/2 queue next to an independent switch, its stationary
+// distribution is the product of (9, 6, 4) / 19 and (On, Off) = (4, 1) / 5
+const queue = `
+identifiers
+  lambda = 2;
+  mu = 3;
+
+events
+  loc l_arr (lambda);
+  loc l_srv (mu);
+  loc l_off (1);
+  loc l_on (4);
+
+reachability = 1;
+
+network Queue (continuous)
+  aut Queue
+    stt Q0 to (Q1) l_arr
+    stt Q1 to (Q2) l_arr
+           to (Q0) l_srv
+    stt Q2 to (Q1) l_srv
+  aut Switch
+    stt On to (Off) l_off
+    stt Off to (On) l_on
+`
+
+var expected = []float64{
+	9. / 19 * 4 / 5, 9. / 19 / 5,
+	6. / 19 * 4 / 5, 6. / 19 / 5,
+	4. / 19 * 4 / 5, 4. / 19 / 5,
+}
+
+func TestSolve(t *testing.T) {
+	var testData = []Options{
+		{Method: MethodPower},
+		{Method: MethodPower, Descriptor: true},
+		{Method: MethodJacobi, Relaxation: 0.8},
+		{Method: MethodJacobi, Relaxation: 0.8, Descriptor: true},
+		{Method: MethodGaussSeidel},
+		{Method: MethodGaussSeidel, Relaxation: 1.2},
+		{Method: MethodPower, Initial: []float64{1, 0, 0, 0, 0, 0}},
+		{Method: MethodGMRES},
+		{Method: MethodGMRES, Restart: 2},
+		{Method: MethodGMRES, Preconditioner: PreconditionDiagonal, Descriptor: true},
+		{Method: MethodGMRES, Preconditioner: PreconditionILU0},
+		{Method: MethodArnoldi},
+		{Method: MethodArnoldi, Restart: 3, Descriptor: true},
+		{Method: MethodArnoldi, Preconditioner: PreconditionDiagonal},
+		{Method: MethodArnoldi, Preconditioner: PreconditionILU0},
+	}
+
+	for _, opts := range testData {
+		res, err := Solve(parse(t, queue), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Converged || res.Residual > 1e-8 {
+			t.Errorf("Expected %+v to converge, got %d iterations with residual %v", opts, res.Iterations, res.Residual)
+		}
+		assertVector(t, expected, res.Probabilities, 1e-8)
+	}
+}
+
+func TestSolve_Reachability(t *testing.T) {
+	src := `
+events
+  syn s_req (2);
+  syn s_resp (3);
+  loc l_proc (5);
+partial reachability = (st Client == Idle) && (st Server == Idle);
+network ClientServer (continuous)
+  aut Client
+    stt Idle to (Waiting) s_req
+    stt Waiting to (Idle) s_resp
+  aut Server
+    stt Idle to (Busy) s_req
+    stt Busy to (Done) l_proc
+    stt Done to (Idle) s_resp
+`
+	// the unreachable state Client=Idle Server=Done is absorbing, which only
+	// the descriptors get to see
+	total := 1./2 + 1./5 + 1./3
+	exp := []float64{1. / 2 / total, 0, 0, 0, 1. / 5 / total, 1. / 3 / total}
+	for _, opts := range []Options{
+		{Method: MethodPower},
+		{Method: MethodPower, Descriptor: true},
+		{Method: MethodJacobi, Relaxation: 0.8, Descriptor: true},
+		{Method: MethodGaussSeidel},
+		{Method: MethodGMRES, Preconditioner: PreconditionILU0},
+		{Method: MethodGMRES, Preconditioner: PreconditionDiagonal, Descriptor: true},
+		{Method: MethodArnoldi, Preconditioner: PreconditionDiagonal, Descriptor: true},
+	} {
+		res, err := Solve(parse(t, src), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertVector(t, exp, res.Probabilities, 1e-8)
+	}
+
+	_, err := Solve(parse(t, src), Options{Initial: []float64{0, 0, 1, 0, 0, 0}})
+	if err == nil || !strings.Contains(err.Error(), "Initial vector holds the unreachable state Client=Idle Server=Done") {
+		t.Errorf("Expected an error for an unreachable initial state, got %v", err)
+	}
+
+	// Client=Idle Server=Done becomes the second reachable state, which is
+	// absorbing
+	absorbing := strings.Replace(src, "stt Done to (Idle) s_resp", "stt Done to (Done) s_resp", 1)
+	for _, opts := range []Options{
+		{Method: MethodJacobi},
+		{Method: MethodJacobi, Descriptor: true},
+		{Method: MethodGMRES, Preconditioner: PreconditionDiagonal, Descriptor: true},
+	} {
+		_, err := Solve(parse(t, absorbing), opts)
+		if err == nil || !strings.Contains(err.Error(), "Global state Client=Idle Server=Done has no outgoing transitions") {
+			t.Errorf("Expected an error for an absorbing reachable state with %+v, got %v", opts, err)
+		}
+	}
+}
+
+func TestSolve_Stiff(t *testing.T) {
+	src := strings.Replace(queue, "lambda = 2;", "lambda = 0.001;", 1)
+	src = strings.Replace(src, "mu = 3;", "mu = 1000;", 1)
+	rho := 1e-6
+	exp := []float64{}
+	for _, q := range []float64{1, rho, rho * rho} {
+		for _, s := range []float64{4. / 5, 1. / 5} {
+			exp = append(exp, q/(1+rho+rho*rho)*s)
+		}
+	}
+
+	for _, opts := range []Options{
+		{Method: MethodGMRES, Preconditioner: PreconditionILU0, MaxIterations: 100},
+		{Method: MethodArnoldi, Preconditioner: PreconditionILU0, MaxIterations: 100},
+		{Method: MethodGMRES, Preconditioner: PreconditionDiagonal, Descriptor: true, MaxIterations: 100},
+	} {
+		res, err := Solve(parse(t, src), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Converged {
+			t.Errorf("Expected %+v to converge, got %d iterations with residual %v", opts, res.Iterations, res.Residual)
+		}
+		assertVector(t, exp, res.Probabilities, 1e-10)
+	}
+}
+
+func TestSolve_Discrete(t *testing.T) {
+	src := `
+events
+  loc l_flip (0.25);
+reachability = 1;
+network Coin (discrete)
+  aut Coin
+    stt Heads to (Tails) l_flip
+    stt Tails to (Heads) l_flip
+`
+	for _, method := range []Method{MethodPower, MethodJacobi, MethodGaussSeidel, MethodGMRES, MethodArnoldi} {
+		res, err := Solve(parse(t, src), Options{Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertVector(t, []float64{0.5, 0.5}, res.Probabilities, 1e-8)
+	}
+}
+
+func TestSolve_NotConverged(t *testing.T) {
+	res, err := Solve(parse(t, queue), Options{MaxIterations: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Converged || res.Iterations != 3 || res.Delta == 0 {
+		t.Errorf("Expected to stop after 3 iterations without converging, got %+v", res)
+	}
+}
+
+func TestSolve_Error(t *testing.T) {
+	absorbing := strings.Replace(queue, "stt Q2 to (Q1) l_srv", "stt Q2 to (Q2) l_srv", 1)
+	absorbing = strings.Replace(absorbing, "stt Off to (On) l_on", "stt Off to (Off) l_on", 1)
+	var testData = []struct {
+		src      string
+		opts     Options
+		expected string
+	}{
+		{queue, Options{Method: "foo"}, `Unknown method "foo"`},
+		{queue, Options{Method: MethodGaussSeidel, Descriptor: true}, "Gauss-Seidel needs the columns of the generator"},
+		{queue, Options{Initial: []float64{1}}, "Initial vector has 1 elements, expected 6"},
+		{queue, Options{Method: MethodGMRES, Preconditioner: "foo"}, `Unknown preconditioner "foo"`},
+		{queue, Options{Method: MethodGMRES, Preconditioner: PreconditionILU0, Descriptor: true}, "ILU(0) needs the elements of the generator"},
+		{absorbing, Options{Method: MethodGMRES, Preconditioner: PreconditionILU0}, "Global state Queue=Q2 Switch=Off has no outgoing transitions"},
+		{absorbing, Options{Method: MethodGMRES, Preconditioner: PreconditionDiagonal, Descriptor: true}, "Global state Queue=Q2 Switch=Off has no outgoing transitions"},
+		{absorbing, Options{Method: MethodJacobi}, "Global state Queue=Q2 Switch=Off has no outgoing transitions"},
+		{absorbing, Options{Method: MethodJacobi, Descriptor: true}, "Global state Queue=Q2 Switch=Off has no outgoing transitions"},
+		{absorbing, Options{Method: MethodGaussSeidel}, "Global state Queue=Q2 Switch=Off has no outgoing transitions"},
+	}
+
+	for _, d := range testData {
+		_, err := Solve(parse(t, d.src), d.opts)
+		if err == nil || !strings.Contains(err.Error(), d.expected) {
+			t.Errorf("want: %q got: %v", d.expected, err)
+		}
+	}
+}
+
+func TestTransient(t *testing.T) {
+	// starting with the switch on, P(On) = 4/5 + 1/5 * exp(-5t)
+	for _, scale := range []float64{1, 1000} {
+		src := strings.Replace(queue, "loc l_off (1);", fmt.Sprintf("loc l_off (%v);", scale), 1)
+		src = strings.Replace(src, "loc l_on (4);", fmt.Sprintf("loc l_on (%v);", 4*scale), 1)
+		m := parse(t, src)
+		x0, err := InitialDistribution(m, map[string]string{"Queue": "Q0", "Switch": "On"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		times := []float64{1 / scale, 0, 10 / scale, 0.1 / scale}
+		for _, descriptor := range []bool{false, true} {
+			results, err := Transient(m, times, Options{Initial: x0, Descriptor: descriptor})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, res := range results {
+				if res.Time != times[i] {
+					t.Errorf("want: %v got: %v", times[i], res.Time)
+				}
+				exp := 0.8 + 0.2*math.Exp(-5*times[i]*scale)
+				p, err := res.Probability(map[string]string{"Switch": "On"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if math.Abs(p-exp) > 1e-9 {
+					t.Errorf("want: %v got: %v at %v", exp, p, times[i])
+				}
+			}
+		}
+	}
+}
+
+func TestTransient_Discrete(t *testing.T) {
+	src := `
+events
+  loc l_flip (0.25);
+reachability = 1;
+network Coin (discrete)
+  aut Coin
+    stt Heads to (Tails) l_flip
+    stt Tails to (Heads) l_flip
+`
+	results, err := Transient(parse(t, src), []float64{2, 0, 1}, Options{Initial: []float64{1, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertVector(t, []float64{0.625, 0.375}, results[0].Probabilities, 1e-12)
+	assertVector(t, []float64{1, 0}, results[1].Probabilities, 1e-12)
+	assertVector(t, []float64{0.75, 0.25}, results[2].Probabilities, 1e-12)
+
+	if _, err := Transient(parse(t, src), []float64{1.5}, Options{Initial: []float64{1, 0}}); err == nil || !strings.Contains(err.Error(), "Invalid number of steps 1.5") {
+		t.Errorf("Expected an error for a fractional number of steps, got %v", err)
+	}
+}
+
+func TestTransient_Error(t *testing.T) {
+	var testData = []struct {
+		times    []float64
+		opts     Options
+		expected string
+	}{
+		{[]float64{1}, Options{}, "Transient analysis needs an initial distribution"},
+		{[]float64{-1}, Options{Initial: expected}, "Invalid time point -1"},
+		{[]float64{1}, Options{Initial: []float64{1}}, "Initial vector has 1 elements, expected 6"},
+	}
+
+	for _, d := range testData {
+		_, err := Transient(parse(t, queue), d.times, d.opts)
+		if err == nil || !strings.Contains(err.Error(), d.expected) {
+			t.Errorf("want: %q got: %v", d.expected, err)
+		}
+	}
+
+	m := parse(t, queue)
+	for _, states := range []map[string]string{{"Queue": "Q0"}, {"Queue": "Q0", "Switch": "Broken"}, {"Foo": "Q0"}} {
+		if _, err := InitialDistribution(m, states); err == nil {
+			t.Errorf("Expected an error for %v", states)
+		}
+	}
+}
+
+func TestFoxGlynn(t *testing.T) {
+	for _, lambda := range []float64{0.5, 3, 250, 1e5} {
+		p := foxGlynn(lambda, 1e-10)
+		// compares against the Poisson probabilities computed in log space
+		for i, w := range p.weights {
+			k := float64(p.left + i)
+			lgamma, _ := math.Lgamma(k + 1)
+			exp := math.Exp(-lambda + k*math.Log(lambda) - lgamma)
+			if math.Abs(w-exp) > 1e-9 {
+				t.Errorf("want: %v got: %v for k = %v and lambda = %v", exp, w, k, lambda)
+				break
+			}
+		}
+	}
+}
+
+func TestEvaluateResults(t *testing.T) {
+	src := queue + `
+results
+  empty = st Queue == Q0;
+  on = st Switch == On;
+  throughput = mu * (st Queue != Q0);
+  waiting = (st Queue == Q2) * (st Switch == Off);
+`
+	m := parse(t, src)
+	res, err := Solve(m, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := EvaluateResults(m, res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := map[string]float64{
+		"empty":      9. / 19,
+		"on":         4. / 5,
+		"throughput": 3 * 10. / 19,
+		"waiting":    4. / 19 / 5,
+	}
+	if len(values) != len(exp) {
+		t.Errorf("want: %v got: %v", exp, values)
+	}
+	for label, v := range exp {
+		if math.Abs(values[label]-v) > 1e-8 {
+			t.Errorf("want: %v got: %v for %s", v, values[label], label)
+		}
+	}
+
+	// transient distributions are evaluated the same way
+	results, err := Transient(m, []float64{0}, Options{Initial: []float64{0, 0, 0, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values, err = EvaluateResults(m, results[0]); err != nil {
+		t.Fatal(err)
+	}
+	if values["waiting"] != 1 || values["throughput"] != 3 || values["on"] != 0 {
+		t.Errorf("Unexpected values on the initial state: %v", values)
+	}
+
+	m.Results[0].Expr = nil
+	if _, err := EvaluateResults(m, res); err == nil || !strings.Contains(err.Error(), `Unable to evaluate result "empty"`) {
+		t.Errorf("Expected an error for a result without expression, got %v", err)
+	}
+}
+
+func TestAbsorbing(t *testing.T) {
+	var testData = []struct {
+		src       string
+		initial   map[string]string
+		meanTime  float64
+		classes   [][]string
+		absorbing []float64
+	}{
+		{`
+events
+  loc l_degrade (2);
+  loc l_repair (1);
+  loc l_fail (1);
+reachability = 1;
+network Component (continuous)
+  aut Component
+    stt Up to (Degraded) l_degrade
+    stt Degraded to (Up) l_repair
+                 to (Failed) l_fail
+    stt Failed
+`, nil, 2, [][]string{{"Component=Failed"}}, []float64{1}},
+		{`
+events
+  loc l_a (1);
+  loc l_b (3);
+  loc l_swap (5);
+partial reachability = st Process == Start;
+network Process (continuous)
+  aut Process
+    stt Start to (A) l_a
+              to (B) l_b
+    stt A
+    stt B to (C) l_swap
+    stt C to (B) l_swap
+`, nil, 0.25, [][]string{{"Process=A"}, {"Process=B", "Process=C"}}, []float64{0.25, 0.75}},
+		{`
+events
+  loc l_flip (0.25);
+reachability = 1;
+network Coin (discrete)
+  aut Coin
+    stt Heads to (Tails) l_flip
+    stt Tails
+`, map[string]string{"Coin": "Heads"}, 4, [][]string{{"Coin=Tails"}}, []float64{1}},
+	}
+
+	for _, d := range testData {
+		m := parse(t, d.src)
+		opts := Options{}
+		if d.initial != nil {
+			x0, err := InitialDistribution(m, d.initial)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts.Initial = x0
+		}
+		res, err := Absorbing(m, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Converged || math.Abs(res.MeanTime-d.meanTime) > 1e-8 {
+			t.Errorf("want: %v got: %v", d.meanTime, res.MeanTime)
+		}
+		if len(res.Classes) != len(d.classes) {
+			t.Fatalf("want: %v got: %d classes", d.classes, len(res.Classes))
+		}
+		for i, class := range res.Classes {
+			if strings.Join(class.Names, ", ") != strings.Join(d.classes[i], ", ") || math.Abs(class.Probability-d.absorbing[i]) > 1e-8 {
+				t.Errorf("want: %v with %v got: %v with %v", d.classes[i], d.absorbing[i], class.Names, class.Probability)
+			}
+		}
+	}
+}
+
+func TestAbsorbing_Error(t *testing.T) {
+	if _, err := Absorbing(parse(t, queue), Options{}); err == nil || !strings.Contains(err.Error(), `Network "Queue" is irreducible, it has no absorbing states`) {
+		t.Errorf("Expected an error for a network without absorbing states, got %v", err)
+	}
+	if _, err := Absorbing(parse(t, queue), Options{Descriptor: true}); err == nil {
+		t.Error("Expected an error for descriptors")
+	}
+}
+
+func TestResultDecode(t *testing.T) {
+	res, err := Solve(parse(t, queue), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	states := res.Decode(3)
+	if states["Queue"] != "Q1" || states["Switch"] != "Off" {
+		t.Errorf("want: Queue=Q1 Switch=Off got: %v", states)
+	}
+
+	p, err := res.Probability(map[string]string{"Switch": "On"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(p-0.8) > 1e-8 {
+		t.Errorf("want: 0.8 got: %v", p)
+	}
+	if _, err := res.Probability(map[string]string{"Switch": "Broken"}); err == nil {
+		t.Error("Expected an error for an unknown state")
+	}
+}
+
+func parse(t *testing.T, src string) *model.Model {
+	m, err := san.Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func assertVector(t *testing.T, exp, act []float64, tolerance float64) {
+	if len(exp) != len(act) {
+		t.Fatalf("want: %v got: %v", exp, act)
+	}
+	for i := range exp {
+		if math.Abs(exp[i]-act[i]) > tolerance {
+			t.Errorf("want: %v got: %v", exp, act)
+			return
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package sansolve
+
+import (
+	"fmt"
+	"math"
+
+	sanctmc "github.com/fgrehm/go-san/ctmc"
+)
+
+// uniformizationFactor keeps the uniformization rate of the power method
+// above the largest exit rate, which prevents the iteration matrix from being
+// periodic
+const uniformizationFactor = 1.01
+
+// Power computes the stationary distribution through the power method over
+// the uniformized chain P = I + Q / lambda
+func Power(op Operator, opts Options) (*Result, error) {
+	opts = opts.withDefaults()
+	diag, err := op.Diagonal()
+	if err != nil {
+		return nil, err
+	}
+	lambda := 0.0
+	for _, d := range diag {
+		lambda = math.Max(lambda, -d)
+	}
+	if lambda == 0 {
+		lambda = 1
+	}
+	lambda *= uniformizationFactor
+
+	return iterate(op, opts, func(x, xq, next []float64) {
+		for j := range next {
+			next[j] = x[j] + xq[j]/lambda
+		}
+	})
+}
+
+// Jacobi computes the stationary distribution through the (over)relaxed
+// Jacobi method, every state must have a nonzero diagonal element
+func Jacobi(op Operator, opts Options) (*Result, error) {
+	diag, err := op.Diagonal()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDiagonal(diag); err != nil {
+		return nil, err
+	}
+	return jacobi(op, diag, opts)
+}
+
+// jacobi runs the Jacobi method with the given diagonal, the elements of the
+// states whose diagonal element is zero are kept at zero
+func jacobi(op Operator, diag []float64, opts Options) (*Result, error) {
+	opts = opts.withDefaults()
+	w := opts.Relaxation
+	return iterate(op, opts, func(x, xq, next []float64) {
+		// x * Q without the diagonal is xq - x * diag
+		for j := range next {
+			if diag[j] == 0 {
+				next[j] = 0
+				continue
+			}
+			next[j] = (1-w)*x[j] + w*(x[j]-xq[j]/diag[j])
+		}
+	})
+}
+
+// GaussSeidel computes the stationary distribution through the (over)relaxed
+// Gauss-Seidel method, which needs the columns of a flat generator
+func GaussSeidel(q *sanctmc.Matrix, opts Options) (*Result, error) {
+	opts = opts.withDefaults()
+	diag := q.Diagonal()
+	if err := checkDiagonal(diag); err != nil {
+		return nil, err
+	}
+	x, err := initial(q.Size(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	cols := q.Transpose()
+	prev := make([]float64, len(x))
+	res := &Result{}
+	w := opts.Relaxation
+	for res.Iterations < opts.MaxIterations && !res.Converged {
+		copy(prev, x)
+		for j := range x {
+			sum := 0.0
+			for k := cols.RowPtr[j]; k < cols.RowPtr[j+1]; k++ {
+				if i := cols.ColIdx[k]; i != j {
+					sum += x[i] * cols.Val[k]
+				}
+			}
+			x[j] = (1-w)*x[j] + w*(-sum/diag[j])
+		}
+		if err := normalize(x); err != nil {
+			return nil, err
+		}
+		res.Iterations++
+		res.Delta = maxDiff(x, prev)
+		res.Converged = res.Delta < opts.Tolerance
+	}
+	return finish(NewMatrixOperator(q), x, res)
+}
+
+// iterate runs an iterative method whose step computes the next vector out
+// of the current one and of its product by the generator
+func iterate(op Operator, opts Options, step func(x, xq, next []float64)) (*Result, error) {
+	x, err := initial(op.Size(), opts)
+	if err != nil {
+		return nil, err
+	}
+	xq := make([]float64, len(x))
+	next := make([]float64, len(x))
+	res := &Result{}
+	for res.Iterations < opts.MaxIterations && !res.Converged {
+		if err := op.VecMul(x, xq); err != nil {
+			return nil, err
+		}
+		step(x, xq, next)
+		if err := normalize(next); err != nil {
+			return nil, err
+		}
+		res.Iterations++
+		res.Delta = maxDiff(x, next)
+		res.Converged = res.Delta < opts.Tolerance
+		x, next = next, x
+	}
+	return finish(op, x, res)
+}
+
+// finish stores the solution on the result along with its residual
+func finish(op Operator, x []float64, res *Result) (*Result, error) {
+	xq := make([]float64, len(x))
+	if err := op.VecMul(x, xq); err != nil {
+		return nil, err
+	}
+	res.Probabilities = x
+	for _, v := range xq {
+		res.Residual = math.Max(res.Residual, math.Abs(v))
+	}
+	return res, nil
+}
+
+func initial(n int, opts Options) ([]float64, error) {
+	x := make([]float64, n)
+	if opts.Initial == nil {
+		for i := range x {
+			x[i] = 1 / float64(n)
+		}
+		return x, nil
+	}
+	if len(opts.Initial) != n {
+		return nil, fmt.Errorf("Initial vector has %d elements, expected %d", len(opts.Initial), n)
+	}
+	copy(x, opts.Initial)
+	if err := normalize(x); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func normalize(x []float64) error {
+	sum := 0.0
+	for _, v := range x {
+		sum += v
+	}
+	if sum == 0 || math.IsNaN(sum) || math.IsInf(sum, 0) {
+		return fmt.Errorf("Unable to normalize a vector that adds up to %v", sum)
+	}
+	for i := range x {
+		x[i] /= sum
+	}
+	return nil
+}
+
+func checkDiagonal(diag []float64) error {
+	for i, d := range diag {
+		if d == 0 {
+			return fmt.Errorf("Global state %d has no outgoing transitions", i)
+		}
+	}
+	return nil
+}
+
+func maxDiff(x, y []float64) float64 {
+	diff := 0.0
+	for i := range x {
+		diff = math.Max(diff, math.Abs(x[i]-y[i]))
+	}
+	return diff
+}
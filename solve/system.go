@@ -0,0 +1,141 @@
+package sansolve
+
+import (
+	"fmt"
+
+	sanctmc "github.com/fgrehm/go-san/ctmc"
+	sandescriptor "github.com/fgrehm/go-san/descriptor"
+	model "github.com/fgrehm/go-san/model"
+)
+
+// system is the generator solvers work on. Flat generators are restricted to
+// the reachable states of the network while descriptors span the whole
+// product state space, in which case the reachable states are only used to
+// build the default initial vector and to leave the unreachable states out of
+// the diagonal.
+type system struct {
+	op        Operator
+	q         *sanctmc.Matrix // nil for descriptors
+	space     *sanctmc.StateSpace
+	reachable *sanctmc.Subspace
+}
+
+func newSystem(m *model.Model, descriptor bool) (*system, error) {
+	n, err := sanctmc.Compile(m)
+	if err != nil {
+		return nil, err
+	}
+	reachable, err := n.Reachable()
+	if err != nil {
+		return nil, err
+	}
+	s := &system{space: n.Space, reachable: reachable}
+
+	if descriptor {
+		d, err := sandescriptor.New(m)
+		if err != nil {
+			return nil, err
+		}
+		s.op = d
+		return s, nil
+	}
+	if s.q, err = n.SubspaceGenerator(reachable); err != nil {
+		return nil, err
+	}
+	s.op = NewMatrixOperator(s.q)
+	return s, nil
+}
+
+// initial maps an initial vector over global states to the states of the
+// system, nil stands for the uniform distribution over the reachable states
+func (s *system) initial(global []float64) ([]float64, error) {
+	if global == nil {
+		global = make([]float64, s.space.Len())
+		for _, g := range s.reachable.States {
+			global[g] = 1 / float64(s.reachable.Len())
+		}
+	}
+	if len(global) != s.space.Len() {
+		return nil, fmt.Errorf("Initial vector has %d elements, expected %d", len(global), s.space.Len())
+	}
+	if s.q == nil {
+		return global, nil
+	}
+
+	x := make([]float64, s.reachable.Len())
+	for g, v := range global {
+		if v == 0 {
+			continue
+		}
+		i, ok := s.reachable.Index(g)
+		if !ok {
+			return nil, fmt.Errorf("Initial vector holds the unreachable state %s", s.space.Name(g))
+		}
+		x[i] = v
+	}
+	return x, nil
+}
+
+// diagonal returns the diagonal of the generator, which must be nonzero on
+// every reachable state. The elements of the unreachable states descriptors
+// span are set to zero.
+func (s *system) diagonal() ([]float64, error) {
+	diag, err := s.op.Diagonal()
+	if err != nil {
+		return nil, err
+	}
+	if s.q != nil {
+		for i, d := range diag {
+			if d == 0 {
+				return nil, fmt.Errorf("Global state %s has no outgoing transitions", s.space.Name(s.reachable.States[i]))
+			}
+		}
+		return diag, nil
+	}
+
+	reachable := make([]float64, len(diag))
+	for _, g := range s.reachable.States {
+		if diag[g] == 0 {
+			return nil, fmt.Errorf("Global state %s has no outgoing transitions", s.space.Name(g))
+		}
+		reachable[g] = diag[g]
+	}
+	return reachable, nil
+}
+
+// preconditioner builds a preconditioner of the generator, the unreachable
+// states descriptors span are left unscaled by the diagonal preconditioner
+func (s *system) preconditioner(kind Preconditioning) (Preconditioner, error) {
+	switch kind {
+	case PreconditionNone:
+		return nil, nil
+	case PreconditionDiagonal:
+		diag, err := s.diagonal()
+		if err != nil {
+			return nil, err
+		}
+		return newDiagonal(diag), nil
+	case PreconditionILU0:
+		if s.q == nil {
+			return nil, fmt.Errorf("ILU(0) needs the elements of the generator, which descriptors do not provide")
+		}
+		if _, err := s.diagonal(); err != nil {
+			return nil, err
+		}
+		return NewILU0Preconditioner(s.q)
+	}
+	return nil, fmt.Errorf("Unknown preconditioner %q", kind)
+}
+
+// finish maps the probabilities of a result back to global states
+func (s *system) finish(res *Result) {
+	res.Space = s.space
+	if s.q == nil {
+		return
+	}
+	global := make([]float64, s.space.Len())
+	for i, v := range res.Probabilities {
+		global[s.reachable.States[i]] = v
+	}
+	res.Probabilities = global
+}
@@ -0,0 +1,208 @@
+package sansolve
+
+import (
+	"fmt"
+	"math"
+
+	sanctmc "github.com/fgrehm/go-san/ctmc"
+	model "github.com/fgrehm/go-san/model"
+)
+
+// InitialDistribution returns the distribution concentrated on the global
+// state made up of the given local states, one for each automaton
+func InitialDistribution(m *model.Model, states map[string]string) ([]float64, error) {
+	space, err := sanctmc.NewStateSpace(m.Network)
+	if err != nil {
+		return nil, err
+	}
+	global, err := space.Lookup(states)
+	if err != nil {
+		return nil, err
+	}
+	x := make([]float64, space.Len())
+	x[global] = 1
+	return x, nil
+}
+
+// Transient computes the distribution of the network of a model at each of
+// the given time points, starting from Options.Initial, which must only hold
+// reachable states. Continuous networks are solved through uniformization,
+// with Options.Tolerance bounding the truncation error of each distribution,
+// while the time points of discrete networks are numbers of steps.
+func Transient(m *model.Model, times []float64, opts Options) ([]*Result, error) {
+	opts = opts.withDefaults()
+	if opts.Initial == nil {
+		return nil, fmt.Errorf("Transient analysis needs an initial distribution")
+	}
+
+	sys, err := newSystem(m, opts.Descriptor)
+	if err != nil {
+		return nil, err
+	}
+	x0, err := sys.initial(opts.Initial)
+	if err != nil {
+		return nil, err
+	}
+	if err := normalize(x0); err != nil {
+		return nil, err
+	}
+
+	var results []*Result
+	if m.Network.IsDiscrete() {
+		results, err = Steps(sys.op, x0, times)
+	} else {
+		results, err = Uniformization(sys.op, x0, times, opts.Tolerance)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range results {
+		sys.finish(res)
+	}
+	return results, nil
+}
+
+// Uniformization computes x0 * exp(Q * t) for each of the given times as the
+// Poisson weighted sum of x0 * P^k, P = I + Q / lambda being the uniformized
+// chain. The series is truncated through foxGlynn so that the error of each
+// distribution stays under epsilon.
+func Uniformization(op Operator, x0 []float64, times []float64, epsilon float64) ([]*Result, error) {
+	diag, err := op.Diagonal()
+	if err != nil {
+		return nil, err
+	}
+	lambda := 0.0
+	for _, d := range diag {
+		lambda = math.Max(lambda, -d)
+	}
+	if lambda == 0 {
+		lambda = 1
+	}
+
+	results := make([]*Result, len(times))
+	weights := make([]*poisson, len(times))
+	right := 0
+	for i, t := range times {
+		if t < 0 || math.IsNaN(t) || math.IsInf(t, 0) {
+			return nil, fmt.Errorf("Invalid time point %v", t)
+		}
+		weights[i] = foxGlynn(lambda*t, epsilon)
+		if weights[i].right() > right {
+			right = weights[i].right()
+		}
+		results[i] = &Result{
+			Probabilities: make([]float64, len(x0)),
+			Iterations:    weights[i].right(),
+			Time:          t,
+			Converged:     true,
+		}
+	}
+
+	x := append([]float64{}, x0...)
+	xq := make([]float64, len(x))
+	for k := 0; ; k++ {
+		for i, w := range weights {
+			if k < w.left || k > w.right() {
+				continue
+			}
+			axpy(w.weights[k-w.left], x, results[i].Probabilities)
+		}
+		if k == right {
+			break
+		}
+		if err := op.VecMul(x, xq); err != nil {
+			return nil, err
+		}
+		axpy(1/lambda, xq, x)
+	}
+	return results, nil
+}
+
+// Steps computes x0 * P^k of a discrete network for each of the given
+// numbers of steps k
+func Steps(op Operator, x0 []float64, steps []float64) ([]*Result, error) {
+	results := make([]*Result, len(steps))
+	last := 0
+	for i, k := range steps {
+		if k < 0 || k != math.Trunc(k) || k > math.MaxInt32 {
+			return nil, fmt.Errorf("Invalid number of steps %v", k)
+		}
+		if int(k) > last {
+			last = int(k)
+		}
+		results[i] = &Result{Time: k, Iterations: int(k), Converged: true}
+	}
+
+	x := append([]float64{}, x0...)
+	xq := make([]float64, len(x))
+	for k := 0; ; k++ {
+		for i, res := range results {
+			if res.Iterations == k {
+				results[i].Probabilities = append([]float64{}, x...)
+			}
+		}
+		if k == last {
+			break
+		}
+		if err := op.VecMul(x, xq); err != nil {
+			return nil, err
+		}
+		axpy(1, xq, x)
+	}
+	return results, nil
+}
+
+// poisson holds the weights of the Poisson probabilities from left on
+type poisson struct {
+	left    int
+	weights []float64
+}
+
+func (p *poisson) right() int {
+	return p.left + len(p.weights) - 1
+}
+
+// foxGlynn computes the Poisson probabilities of rate lambda that are needed
+// to keep the truncation error under epsilon. As in the algorithm of Fox and
+// Glynn the weights are computed outwards from the mode, which avoids
+// underflows, and normalized at the end. The tails are truncated once their
+// geometric bound falls under epsilon / 2 of the weight accumulated so far.
+func foxGlynn(lambda, epsilon float64) *poisson {
+	if lambda == 0 {
+		return &poisson{left: 0, weights: []float64{1}}
+	}
+
+	mode := int(math.Floor(lambda))
+	total := 1.0
+	// the weights on the left of the mode are stored in reverse order
+	left := []float64{1}
+	for k := mode; k > 0; k-- {
+		ratio := float64(k) / lambda
+		w := left[len(left)-1] * ratio
+		left = append(left, w)
+		total += w
+		// the remaining terms decrease faster than a geometric series
+		if r := float64(k-1) / lambda; w*r/(1-r) <= epsilon/2*total {
+			break
+		}
+	}
+	right := []float64{}
+	w := 1.0
+	for k := mode + 1; ; k++ {
+		w *= lambda / float64(k)
+		right = append(right, w)
+		total += w
+		if r := lambda / float64(k+1); w*r/(1-r) <= epsilon/2*total {
+			break
+		}
+	}
+
+	p := &poisson{left: mode - len(left) + 1}
+	for i := len(left) - 1; i >= 0; i-- {
+		p.weights = append(p.weights, left[i]/total)
+	}
+	for _, w := range right {
+		p.weights = append(p.weights, w/total)
+	}
+	return p
+}
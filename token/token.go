@@ -39,6 +39,12 @@ const (
 	LPAREN
 	// RPAREN represents a right parenthesis
 	RPAREN
+	// LBRACK represents a left bracket
+	LBRACK
+	// RBRACK represents a right bracket
+	RBRACK
+	// RANGE represents the .. used on ranges
+	RANGE
 	// ASSIGN represents an equal sign used on assignments
 	ASSIGN
 	// SUM represents a sum
@@ -49,6 +55,8 @@ const (
 	MULT
 	// DIV represents a division
 	DIV
+	// MOD represents the % operator
+	MOD
 
 	// AND represents the && operator
 	AND
@@ -58,10 +66,28 @@ const (
 	NEG
 	// NEQUAL represents the != operator
 	NEQUAL
+	// OR represents the || operator
+	OR
+	// LT represents the < operator
+	LT
+	// GT represents the > operator
+	GT
+	// LEQ represents the <= operator
+	LEQ
+	// GEQ represents the >= operator
+	GEQ
+	// QUESTION represents the ? of conditional expressions
+	QUESTION
+	// COLON represents the : of conditional expressions
+	COLON
+	// COMMA represents a comma
+	COMMA
 
 	keywordBeg
 	// IDENTIFIERS represents the identifiers keyword
 	IDENTIFIERS
+	// DOMAINS represents the domains keyword
+	DOMAINS
 	// EVENTS represents the events keyword
 	EVENTS
 	// PARTIAL represents the partial keyword
@@ -70,8 +96,12 @@ const (
 	REACHABILITY
 	// NETWORK represents the network keyword
 	NETWORK
+	networkTypeBeg
 	// CONTINUOUS represents the continuous keyword
 	CONTINUOUS
+	// DISCRETE represents the discrete keyword
+	DISCRETE
+	networkTypeEnd
 	eventTypeBeg
 	// LOC represents the loc keyword
 	LOC
@@ -89,6 +119,18 @@ const (
 	// RESULTS represents the results keyword
 	RESULTS
 	keywordEnd
+
+	builtinBeg
+	// MIN represents the min built-in function
+	MIN
+	// MAX represents the max built-in function
+	MAX
+	// NB represents the nb built-in, which counts automata on a given state
+	NB
+	// LST represents the lst built-in, which finds the last automaton on a
+	// given state
+	LST
+	builtinEnd
 )
 
 var tokens = [...]string{
@@ -103,17 +145,31 @@ var tokens = [...]string{
 
 	LPAREN: "LPAREN",
 	RPAREN: "RPAREN",
+	LBRACK: "LBRACK",
+	RBRACK: "RBRACK",
+	RANGE:  "RANGE",
 	ASSIGN: "ASSIGN",
 	SUM:    "SUM",
 	SUB:    "SUB",
 	MULT:   "MULT",
 	DIV:    "DIV",
+	MOD:    "MOD",
 	AND:    "AND",
 	EQUAL:  "EQUAL",
 	NEG:    "NEG",
 	NEQUAL: "NEQUAL",
+	OR:     "OR",
+	LT:     "LT",
+	GT:     "GT",
+	LEQ:    "LEQ",
+	GEQ:    "GEQ",
+
+	QUESTION: "QUESTION",
+	COLON:    "COLON",
+	COMMA:    "COMMA",
 
 	IDENTIFIERS:  "IDENTIFIERS",
+	DOMAINS:      "DOMAINS",
 	EVENTS:       "EVENTS",
 	LOC:          "LOC",
 	SYN:          "SYN",
@@ -121,11 +177,47 @@ var tokens = [...]string{
 	REACHABILITY: "REACHABILITY",
 	NETWORK:      "NETWORK",
 	CONTINUOUS:   "CONTINUOUS",
+	DISCRETE:     "DISCRETE",
 	AUT:          "AUT",
 	STT:          "STT",
 	ST:           "ST",
 	TO:           "TO",
 	RESULTS:      "RESULTS",
+
+	MIN: "MIN",
+	MAX: "MAX",
+	NB:  "NB",
+	LST: "LST",
+}
+
+// A set of constants for precedence-based expression parsing. Non-operators
+// have lowest precedence, followed by operators starting with precedence 1 up
+// to unary operators. The highest precedence serves as "catch-all" precedence
+// for parenthesized expressions, the st construct and built-ins.
+const (
+	LowestPrec  = 0 // non-operators
+	UnaryPrec   = 7
+	HighestPrec = 8
+)
+
+// Precedence returns the operator precedence of the binary operator t. If t
+// is not a binary operator, the result is LowestPrec.
+func (t Type) Precedence() int {
+	switch t {
+	case OR:
+		return 1
+	case AND:
+		return 2
+	case EQUAL, NEQUAL:
+		return 3
+	case LT, GT, LEQ, GEQ:
+		return 4
+	case SUM, SUB:
+		return 5
+	case MULT, DIV, MOD:
+		return 6
+	}
+	return LowestPrec
 }
 
 // IsLiteral returns true for tokens corresponding to basic type literals; it
@@ -136,10 +228,18 @@ func (t Type) IsLiteral() bool { return literalBeg < t && t < literalEnd }
 // loc or syn)
 func (t Type) IsEventType() bool { return eventTypeBeg < t && t < eventTypeEnd }
 
+// IsNetworkType returns true for tokens corresponding to network types
+// (currently continuous or discrete)
+func (t Type) IsNetworkType() bool { return networkTypeBeg < t && t < networkTypeEnd }
+
 // IsKeyword returns true for tokens corresponding to keywords; it returns
 // false otherwise.
 func (t Type) IsKeyword() bool { return keywordBeg < t && t < keywordEnd }
 
+// IsBuiltin returns true for tokens corresponding to built-in functions and
+// operators (min, max, nb and lst); it returns false otherwise.
+func (t Type) IsBuiltin() bool { return builtinBeg < t && t < builtinEnd }
+
 // String returns the string corresponding to the token tok.
 func (t Type) String() string {
 	s := ""
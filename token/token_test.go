@@ -21,6 +21,9 @@ func TestTypeString(t *testing.T) {
 
 		{LPAREN, "LPAREN"},
 		{RPAREN, "RPAREN"},
+		{LBRACK, "LBRACK"},
+		{RBRACK, "RBRACK"},
+		{RANGE, "RANGE"},
 		{ASSIGN, "ASSIGN"},
 		{MULT, "MULT"},
 		{DIV, "DIV"},
@@ -30,13 +33,24 @@ func TestTypeString(t *testing.T) {
 		{EQUAL, "EQUAL"},
 		{NEG, "NEG"},
 		{NEQUAL, "NEQUAL"},
+		{MOD, "MOD"},
+		{OR, "OR"},
+		{LT, "LT"},
+		{GT, "GT"},
+		{LEQ, "LEQ"},
+		{GEQ, "GEQ"},
+		{QUESTION, "QUESTION"},
+		{COLON, "COLON"},
+		{COMMA, "COMMA"},
 
 		{IDENTIFIERS, "IDENTIFIERS"},
+		{DOMAINS, "DOMAINS"},
 		{EVENTS, "EVENTS"},
 		{PARTIAL, "PARTIAL"},
 		{REACHABILITY, "REACHABILITY"},
 		{NETWORK, "NETWORK"},
 		{CONTINUOUS, "CONTINUOUS"},
+		{DISCRETE, "DISCRETE"},
 		{LOC, "LOC"},
 		{SYN, "SYN"},
 		{AUT, "AUT"},
@@ -44,6 +58,11 @@ func TestTypeString(t *testing.T) {
 		{ST, "ST"},
 		{TO, "TO"},
 		{RESULTS, "RESULTS"},
+
+		{MIN, "MIN"},
+		{MAX, "MAX"},
+		{NB, "NB"},
+		{LST, "LST"},
 	}
 
 	for _, token := range tokens {
@@ -69,19 +88,29 @@ func TestKeywords(t *testing.T) {
 
 		{LPAREN, false},
 		{RPAREN, false},
+		{LBRACK, false},
+		{RBRACK, false},
+		{RANGE, false},
 		{ASSIGN, false},
 		{MULT, false},
 		{AND, false},
 		{EQUAL, false},
 		{NEG, false},
 		{NEQUAL, false},
+		{MOD, false},
+		{OR, false},
+		{LT, false},
+		{GEQ, false},
+		{COMMA, false},
 
 		{IDENTIFIERS, true},
+		{DOMAINS, true},
 		{EVENTS, true},
 		{PARTIAL, true},
 		{REACHABILITY, true},
 		{NETWORK, true},
 		{CONTINUOUS, true},
+		{DISCRETE, true},
 		{LOC, true},
 		{SYN, true},
 		{AUT, true},
@@ -89,6 +118,9 @@ func TestKeywords(t *testing.T) {
 		{ST, true},
 		{TO, true},
 		{RESULTS, true},
+
+		{MIN, false},
+		{NB, false},
 	}
 
 	for _, token := range tokens {
@@ -114,19 +146,29 @@ func TestLiterals(t *testing.T) {
 
 		{LPAREN, false},
 		{RPAREN, false},
+		{LBRACK, false},
+		{RBRACK, false},
+		{RANGE, false},
 		{ASSIGN, false},
 		{MULT, false},
 		{AND, false},
 		{EQUAL, false},
 		{NEG, false},
 		{NEQUAL, false},
+		{MOD, false},
+		{OR, false},
+		{LT, false},
+		{GEQ, false},
+		{COMMA, false},
 
 		{IDENTIFIERS, false},
+		{DOMAINS, false},
 		{EVENTS, false},
 		{PARTIAL, false},
 		{REACHABILITY, false},
 		{NETWORK, false},
 		{CONTINUOUS, false},
+		{DISCRETE, false},
 		{LOC, false},
 		{SYN, false},
 		{AUT, false},
@@ -134,6 +176,9 @@ func TestLiterals(t *testing.T) {
 		{ST, false},
 		{TO, false},
 		{RESULTS, false},
+
+		{MIN, false},
+		{NB, false},
 	}
 
 	for _, token := range tokens {
@@ -159,19 +204,29 @@ func TestEventTypes(t *testing.T) {
 
 		{LPAREN, false},
 		{RPAREN, false},
+		{LBRACK, false},
+		{RBRACK, false},
+		{RANGE, false},
 		{ASSIGN, false},
 		{MULT, false},
 		{AND, false},
 		{EQUAL, false},
 		{NEG, false},
 		{NEQUAL, false},
+		{MOD, false},
+		{OR, false},
+		{LT, false},
+		{GEQ, false},
+		{COMMA, false},
 
 		{IDENTIFIERS, false},
+		{DOMAINS, false},
 		{EVENTS, false},
 		{PARTIAL, false},
 		{REACHABILITY, false},
 		{NETWORK, false},
 		{CONTINUOUS, false},
+		{DISCRETE, false},
 		{LOC, true},
 		{SYN, true},
 		{AUT, false},
@@ -179,6 +234,9 @@ func TestEventTypes(t *testing.T) {
 		{ST, false},
 		{TO, false},
 		{RESULTS, false},
+
+		{MIN, false},
+		{NB, false},
 	}
 
 	for _, token := range tokens {
@@ -188,6 +246,51 @@ func TestEventTypes(t *testing.T) {
 	}
 }
 
+func TestNetworkTypes(t *testing.T) {
+	var tokens = []struct {
+		tt          Type
+		networkType bool
+	}{
+		{IDENTIFIER, false},
+		{NETWORK, false},
+		{LOC, false},
+		{AUT, false},
+
+		{CONTINUOUS, true},
+		{DISCRETE, true},
+	}
+
+	for _, token := range tokens {
+		if token.tt.IsNetworkType() != token.networkType {
+			t.Errorf("want: %v got: %v for %s\n", token.networkType, token.tt.IsNetworkType(), token.tt.String())
+		}
+	}
+}
+
+func TestBuiltins(t *testing.T) {
+	var tokens = []struct {
+		tt      Type
+		builtin bool
+	}{
+		{IDENTIFIER, false},
+		{MULT, false},
+		{COMMA, false},
+		{ST, false},
+		{RESULTS, false},
+
+		{MIN, true},
+		{MAX, true},
+		{NB, true},
+		{LST, true},
+	}
+
+	for _, token := range tokens {
+		if token.tt.IsBuiltin() != token.builtin {
+			t.Errorf("want: %v got: %v for %s\n", token.builtin, token.tt.IsBuiltin(), token.tt.String())
+		}
+	}
+}
+
 func TestTokenValue(t *testing.T) {
 	var tokens = []struct {
 		tt Token
@@ -204,3 +307,35 @@ func TestTokenValue(t *testing.T) {
 		}
 	}
 }
+
+func TestPrecedence(t *testing.T) {
+	var tokens = []struct {
+		tt   Type
+		prec int
+	}{
+		{OR, 1},
+		{AND, 2},
+		{EQUAL, 3},
+		{NEQUAL, 3},
+		{LT, 4},
+		{GT, 4},
+		{LEQ, 4},
+		{GEQ, 4},
+		{SUM, 5},
+		{SUB, 5},
+		{MULT, 6},
+		{DIV, 6},
+		{MOD, 6},
+		{QUESTION, LowestPrec},
+		{MIN, LowestPrec},
+		{NEG, LowestPrec},
+		{IDENTIFIER, LowestPrec},
+		{LPAREN, LowestPrec},
+	}
+
+	for _, token := range tokens {
+		if token.tt.Precedence() != token.prec {
+			t.Errorf("want: %d got: %d for %s\n", token.prec, token.tt.Precedence(), token.tt.String())
+		}
+	}
+}
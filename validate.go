@@ -0,0 +1,337 @@
+package san
+
+import (
+	"fmt"
+
+	ast "github.com/fgrehm/go-san/ast"
+	saneval "github.com/fgrehm/go-san/eval"
+	model "github.com/fgrehm/go-san/model"
+	parser "github.com/fgrehm/go-san/parser"
+	token "github.com/fgrehm/go-san/token"
+)
+
+type validator func(*model.Model) []error
+
+var validators = []validator{
+	validateIdentifiers,
+	validateDomains,
+	validateEvents,
+	validateNetwork,
+	validateProbabilities,
+	validateStateReferences,
+}
+
+// Validate performs semantic checks on a model, reporting the mistakes that
+// the parser is not able to catch, like references to undefined events,
+// identifiers, automata or states
+func Validate(m *model.Model) []error {
+	errs := []error{}
+	for _, v := range validators {
+		errs = append(errs, v(m)...)
+	}
+	return errs
+}
+
+func validateIdentifiers(m *model.Model) []error {
+	errs := []error{}
+	seen := map[string]bool{}
+	for _, ident := range m.Identifiers {
+		if seen[ident.Name] {
+			errs = append(errs, posError(ident.Pos, fmt.Errorf("Identifier %q defined more than once", ident.Name)))
+		}
+		seen[ident.Name] = true
+	}
+
+	if _, err := saneval.Evaluate(m); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func validateDomains(m *model.Model) []error {
+	errs := []error{}
+	idents := identifierNames(m)
+	seen := map[string]bool{}
+	for _, domain := range m.Domains {
+		if seen[domain.Name] {
+			errs = append(errs, posError(domain.Pos, fmt.Errorf("Domain %q defined more than once", domain.Name)))
+		}
+		seen[domain.Name] = true
+
+		if idents[domain.Name] {
+			errs = append(errs, fmt.Errorf("Domain %q clashes with an identifier of the same name", domain.Name))
+		}
+	}
+	return errs
+}
+
+func validateEvents(m *model.Model) []error {
+	errs := []error{}
+	idents := identifierNames(m)
+	seen := map[string]bool{}
+	for _, event := range m.Events {
+		if seen[event.Name] {
+			errs = append(errs, posError(event.Pos, fmt.Errorf("Event %q defined more than once", event.Name)))
+		}
+		seen[event.Name] = true
+
+		if event.RateExpr == nil {
+			if !idents[event.Rate] {
+				errs = append(errs, fmt.Errorf("Event %q rate references undefined identifier %q", event.Name, event.Rate))
+			}
+			continue
+		}
+		for _, ident := range undefinedIdentifiers(event.RateExpr, idents) {
+			errs = append(errs, &parser.PosError{Pos: ident.Token.Pos, Err: fmt.Errorf("Event %q rate references undefined identifier %q", event.Name, ident.Token.Text)})
+		}
+	}
+	return errs
+}
+
+func validateNetwork(m *model.Model) []error {
+	errs := []error{}
+	events := map[string]*model.Event{}
+	for _, event := range m.Events {
+		events[event.Name] = event
+	}
+
+	idents := identifierNames(m)
+	seen := map[string]bool{}
+	usage := map[string][]string{}
+	for _, aut := range m.Network.Automata {
+		if seen[aut.Name] {
+			errs = append(errs, posError(aut.Pos, fmt.Errorf("Automaton %q defined more than once", aut.Name)))
+		}
+		seen[aut.Name] = true
+
+		used := map[string]bool{}
+		for _, transition := range aut.Transitions {
+			for _, e := range transition.Events {
+				if _, ok := events[e.EventName]; !ok {
+					errs = append(errs, posError(e.Pos, fmt.Errorf("Transition from %q to %q of automaton %q references undefined event %q", transition.From, transition.To, aut.Name, e.EventName)))
+					continue
+				}
+				for _, ident := range undefinedIdentifiers(e.ProbabilityExpr, idents) {
+					errs = append(errs, &parser.PosError{Pos: ident.Token.Pos, Err: fmt.Errorf("Probability of event %q on automaton %q references undefined identifier %q", e.EventName, aut.Name, ident.Token.Text)})
+				}
+				if !used[e.EventName] {
+					used[e.EventName] = true
+					usage[e.EventName] = append(usage[e.EventName], aut.Name)
+				}
+			}
+		}
+	}
+
+	for _, event := range m.Events {
+		automata := usage[event.Name]
+		switch {
+		case event.Type == "local" && len(owners(m, automata)) > 1:
+			errs = append(errs, fmt.Errorf("Local event %q is used by more than one automaton: %v", event.Name, automata))
+		case event.Type == "synchronizing" && len(automata) < 2:
+			errs = append(errs, fmt.Errorf("Synchronizing event %q must be used by at least two automata, found %d", event.Name, len(automata)))
+		}
+	}
+	return errs
+}
+
+// validateProbabilities checks that routing probabilities and, on discrete
+// networks, event rates lie within [0, 1]. Expressions that depend on automata
+// states can only be checked once the model is solved.
+func validateProbabilities(m *model.Model) []error {
+	errs := []error{}
+	scope, err := saneval.Evaluate(m)
+	if err != nil {
+		// identifiers errors are reported by validateIdentifiers
+		return errs
+	}
+
+	isProbability := func(e ast.Expr) bool {
+		if e == nil {
+			return true
+		}
+		v, err := scope.Eval(e, nil)
+		return err != nil || 0 <= v && v <= 1
+	}
+
+	if m.Network.IsDiscrete() {
+		for _, event := range m.Events {
+			if !isProbability(event.RateExpr) {
+				errs = append(errs, &parser.PosError{Pos: event.RateExpr.Pos(), Err: fmt.Errorf("Event %q rate must be a probability on discrete networks, got %s", event.Name, event.RateExpr)})
+			}
+		}
+	}
+	for _, aut := range m.Network.Automata {
+		for _, transition := range aut.Transitions {
+			for _, e := range transition.Events {
+				if !isProbability(e.ProbabilityExpr) {
+					errs = append(errs, &parser.PosError{Pos: e.ProbabilityExpr.Pos(), Err: fmt.Errorf("Probability of event %q on automaton %q must lie within [0, 1], got %s", e.EventName, aut.Name, e.ProbabilityExpr)})
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// validateStateReferences checks that `st Automaton == state` constructs used
+// on the identifiers, reachability and results expressions reference known
+// automata and states
+func validateStateReferences(m *model.Model) []error {
+	errs := []error{}
+	idents := identifierNames(m)
+	states := map[string]map[string]bool{}
+	for _, aut := range m.Network.Automata {
+		names := map[string]bool{}
+		for _, state := range aut.StateNames() {
+			names[state] = true
+		}
+		states[aut.Name] = names
+	}
+
+	scope, err := saneval.Evaluate(m)
+	if err != nil {
+		// identifiers errors are reported by validateIdentifiers
+		scope = &saneval.Scope{Values: saneval.Values{}}
+	}
+
+	check := func(e ast.Expr) {
+		if e == nil {
+			return
+		}
+		ast.Inspect(e, func(n ast.Expr) bool {
+			switch n := n.(type) {
+			case *ast.StateExpr:
+				name, err := scope.AutomatonName(n)
+				if err != nil {
+					errs = append(errs, err)
+				} else if _, ok := states[name]; !ok {
+					errs = append(errs, &parser.PosError{Pos: n.Automaton.Pos, Err: fmt.Errorf("Unknown automaton %q", name)})
+				}
+			case *ast.BinaryExpr:
+				st, state := saneval.StateComparison(n)
+				if st == nil {
+					break
+				}
+				name, _ := scope.AutomatonName(st)
+				stateName, err := scope.StateName(state)
+				if err != nil {
+					errs = append(errs, err)
+					break
+				}
+				autStates, ok := states[name]
+				if ok && !autStates[stateName] && (state.Index != nil || !idents[stateName]) {
+					errs = append(errs, &parser.PosError{Pos: state.Token.Pos, Err: fmt.Errorf("Unknown state %q for automaton %q", stateName, name)})
+				}
+			case *ast.AggregateExpr:
+				automata := groupAutomata(m, n.Automata.Text)
+				if len(automata) == 0 {
+					errs = append(errs, &parser.PosError{Pos: n.Automata.Pos, Err: fmt.Errorf("Unknown automaton %q", n.Automata.Text)})
+					break
+				}
+				stateName, err := scope.StateName(n.State)
+				if err != nil {
+					errs = append(errs, err)
+					break
+				}
+				for _, name := range automata {
+					if !states[name][stateName] {
+						errs = append(errs, &parser.PosError{Pos: n.State.Token.Pos, Err: fmt.Errorf("Unknown state %q for automaton %q", stateName, name)})
+						break
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	for _, ident := range m.Identifiers {
+		check(ident.Expr)
+	}
+	for _, event := range m.Events {
+		check(event.RateExpr)
+	}
+	for _, aut := range m.Network.Automata {
+		for _, transition := range aut.Transitions {
+			for _, e := range transition.Events {
+				check(e.ProbabilityExpr)
+			}
+		}
+	}
+	check(m.Reachability.Expr)
+	for _, res := range m.Results {
+		check(res.Expr)
+	}
+	return errs
+}
+
+// groupAutomata returns the automaton with the given name or the replicas of
+// the replicated automaton with the given name
+func groupAutomata(m *model.Model, name string) []string {
+	automata := []string{}
+	for _, aut := range m.Network.Automata {
+		if aut.Name == name || aut.Group == name {
+			automata = append(automata, aut.Name)
+		}
+	}
+	return automata
+}
+
+// owners maps automata names to the names of the automata they have been
+// declared as, replicas of an automaton share the same local events
+func owners(m *model.Model, automata []string) []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, name := range automata {
+		owner := name
+		if aut := m.Network.Automaton(name); aut != nil && aut.Group != "" {
+			owner = aut.Group
+		}
+		if !seen[owner] {
+			seen[owner] = true
+			names = append(names, owner)
+		}
+	}
+	return names
+}
+
+// undefinedIdentifiers returns the identifiers referenced by an expression
+// that are not defined on the identifiers block. States compared against the
+// current state of an automaton are not taken into account.
+func undefinedIdentifiers(e ast.Expr, idents map[string]bool) []*ast.Ident {
+	undefined := []*ast.Ident{}
+	if e == nil {
+		return undefined
+	}
+
+	states := map[*ast.Ident]bool{}
+	ast.Inspect(e, func(n ast.Expr) bool {
+		switch n := n.(type) {
+		case *ast.BinaryExpr:
+			if st, state := saneval.StateComparison(n); st != nil {
+				states[state] = true
+			}
+		case *ast.Ident:
+			if !states[n] && !idents[n.Token.Text] {
+				undefined = append(undefined, n)
+			}
+		}
+		return true
+	})
+	return undefined
+}
+
+// posError wraps an error along with the position of the definition it
+// refers to, models that have not been parsed carry no positions
+func posError(pos token.Pos, err error) error {
+	if !pos.IsValid() {
+		return err
+	}
+	return &parser.PosError{Pos: pos, Err: err}
+}
+
+func identifierNames(m *model.Model) map[string]bool {
+	names := map[string]bool{}
+	for _, ident := range m.Identifiers {
+		names[ident.Name] = true
+	}
+	return names
+}
@@ -0,0 +1,116 @@
+package san
+
+import (
+	"strings"
+	"testing"
+)
+
+const clientServer = `
+identifiers
+  r_req = 2;
+  r_resp = 3;
+  r_proc = 5;
+  F1 = (st Client == Idle) * r_proc;
+
+events
+  syn s_req (r_req);
+  syn s_resp (r_resp);
+  loc l_proc (r_proc * (st Client == Waiting));
+
+reachability = 1;
+
+network ClientServer (continuous)
+  aut Client
+    stt Idle to (Waiting) s_req
+    stt Waiting to (Idle) s_resp
+  aut Server
+    stt Idle to (Busy) s_req
+    stt Busy to (Done) l_proc
+    stt Done to (Idle) s_resp
+
+results
+  idle = st Client == Idle;
+  busy = (st Server == Busy) && (st Client == Waiting);
+`
+
+func TestValidate(t *testing.T) {
+	m, err := Parse([]byte(clientServer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := Validate(m); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+}
+
+func TestValidate_ReplicatedAutomata(t *testing.T) {
+	src := `identifiers
+  r = 1;
+events
+  loc l_work (r);
+  syn s_sync (r);
+reachability = 1;
+network Farm (continuous)
+  aut Worker[2]
+    stt Idle to (Busy) l_work
+    stt Busy to (Idle) s_sync
+  aut Monitor
+    stt Watching to (Watching) s_sync
+`
+	m, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := Validate(m); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+}
+
+func TestValidate_Error(t *testing.T) {
+	var testData = []struct {
+		original string
+		replaced string
+		expected string
+	}{
+		{"r_proc = 5;", "r_proc = 5; r_req = 1;", `At 5:15: Identifier "r_req" defined more than once`},
+		{"r_proc = 5;", "r_proc = foo;", `Undefined identifier "foo"`},
+		{"events", "domains D = [0..1]; D = [0..2];\nevents", `At 8:21: Domain "D" defined more than once`},
+		{"events", "domains r_req = [0..1];\nevents", `Domain "r_req" clashes with an identifier of the same name`},
+		{"loc l_proc (r_proc * (st Client == Waiting));", "loc l_proc (r_foo);", `Event "l_proc" rate references undefined identifier "r_foo"`},
+		{"loc l_proc (r_proc * (st Client == Waiting));", "loc l_proc (r_proc * (st Server == Busy) + r_foo);", `At 11:46: Event "l_proc" rate references undefined identifier "r_foo"`},
+		{"loc l_proc (r_proc * (st Client == Waiting));", "loc l_proc (r_proc * (st Server == Bsy));", `At 11:38: Unknown state "Bsy" for automaton "Server"`},
+		{"stt Busy to (Done) l_proc", "stt Busy to (Done) l_proc(1.5)", `Probability of event "l_proc" on automaton "Server" must lie within [0, 1], got 1.5`},
+		{"(continuous)", "(discrete)", `Event "s_req" rate must be a probability on discrete networks, got r_req`},
+		{"stt Busy to (Done) l_proc", "stt Busy to (Done) l_proc(p_foo)", `Probability of event "l_proc" on automaton "Server" references undefined identifier "p_foo"`},
+		{"loc l_proc (r_proc * (st Client == Waiting));", "loc l_proc (r_proc); loc l_proc (r_proc);", `At 11:28: Event "l_proc" defined more than once`},
+		{"stt Busy to (Done) l_proc", "stt Busy to (Done) l_foo", `At 21:24: Transition from "Busy" to "Done" of automaton "Server" references undefined event "l_foo"`},
+		{"stt Waiting to (Idle) s_resp", "stt Waiting to (Idle) s_resp to (Idle) l_proc", `Local event "l_proc" is used by more than one automaton`},
+		{"stt Idle to (Waiting) s_req", "stt Idle to (Waiting) l_proc", `Synchronizing event "s_req" must be used by at least two automata, found 1`},
+		{"aut Server", "aut Client", `At 19:7: Automaton "Client" defined more than once`},
+		{"idle = st Client == Idle;", "idle = st Clients == Idle;", `At 25:13: Unknown automaton "Clients"`},
+		{"idle = st Client == Idle;", "idle = st Client == Idling;", `At 25:23: Unknown state "Idling" for automaton "Client"`},
+		{"reachability = 1;", "reachability = st Foo == Bar;", `At 13:19: Unknown automaton "Foo"`},
+		{"idle = st Client == Idle;", "idle = st Client == Idle[2];", `At 25:23: Unknown state "Idle[2]" for automaton "Client"`},
+		{"idle = st Client == Idle;", "idle = nb [Clients] Idle;", `At 25:14: Unknown automaton "Clients"`},
+		{"idle = st Client == Idle;", "idle = nb [Server] Idling;", `At 25:22: Unknown state "Idling" for automaton "Server"`},
+	}
+
+	for _, d := range testData {
+		m, err := Parse([]byte(strings.Replace(clientServer, d.original, d.replaced, 1)))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		found := false
+		errs := Validate(m)
+		for _, err := range errs {
+			if strings.Contains(err.Error(), d.expected) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected to find error %q, got %v", d.expected, errs)
+		}
+	}
+}